@@ -1,12 +1,21 @@
 package main
 
 import (
+	"os"
+
 	"butterfly.orx.me/core"
 	"butterfly.orx.me/core/app"
 	"github.com/orvice/openapi-proxy/internal/handler"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		if err := handler.Check(os.Stdout); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
 	app := core.New(&app.Config{
 		Service: "openai-proxy",
 		Router:  handler.Router,
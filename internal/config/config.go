@@ -1,19 +1,405 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
 	"strings"
+	"text/template"
+	"time"
 
+	"github.com/orvice/openapi-proxy/internal/redact"
+	"github.com/orvice/openapi-proxy/internal/version"
 	"github.com/spf13/viper"
 )
 
 const (
-	defaultEndpoint = "https://api.openai.com"
+	defaultEndpoint     = "https://api.openai.com"
+	defaultMaxRedirects = 5
+	defaultBudgetWindow = 24 * time.Hour
+	defaultCacheEntries = 1000
+	defaultMaxBodyBytes = 64 << 20
+	defaultUnixHost     = "http://localhost"
+	defaultAWSService   = "bedrock"
+
+	defaultDisabledModelMessage = "model disabled by administrator"
+	defaultModelAlias           = "auto"
+	defaultModelAliasOwnedBy    = "openai-proxy"
+
+	unixScheme = "unix://"
+)
+
+// Supported request signing schemes.
+const (
+	SignerNone  = ""
+	SignerSigV4 = "sigv4"
+)
+
+// How upstream 3xx responses are handled.
+const (
+	RedirectPass    = ""        // return the redirect to the client as-is
+	RedirectFollow  = "follow"  // follow it server-side, re-sending auth
+	RedirectRewrite = "rewrite" // point Location back through the proxy
+)
+
+// Supported ways of presenting the API key to the upstream.
+const (
+	AuthStyleBearer      = "bearer"        // Authorization: Bearer <key>
+	AuthStyleXAPIKey     = "x-api-key"     // x-api-key: <key>
+	AuthStyleAPIKeyQuery = "api-key-query" // ?key=<key>
 )
 
 type Config struct {
 	OpenAIKey      string `mapstructure:"OPENAI_KEY"`
 	OpenAIEndpoint string `mapstructure:"OPENAI_ENDPOINT"`
 	ModelOverride  string `mapstructure:"MODEL_OVERRIDE"`
+
+	// UnixSocket is set when OPENAI_ENDPOINT is a unix:///path/to.sock
+	// address. Requests are then dialed to the socket, with UNIX_SOCKET_HOST
+	// used as the URL in the request line.
+	UnixSocket string `mapstructure:"-"`
+	UnixHost   string `mapstructure:"UNIX_SOCKET_HOST"`
+
+	// TraceTimings logs DNS/connect/TLS/first-byte timings for every
+	// upstream call. Off by default because of the extra overhead.
+	TraceTimings bool `mapstructure:"TRACE_TIMINGS"`
+
+	// UserAgent is sent on every outbound request in place of the client's.
+	UserAgent string `mapstructure:"USER_AGENT"`
+
+	// AuthStyle selects how the key is sent upstream, see AuthStyle*.
+	AuthStyle string `mapstructure:"AUTH_STYLE"`
+
+	// HTTPProxy routes upstream traffic through the given proxy URL instead
+	// of the environment's HTTP_PROXY/HTTPS_PROXY settings.
+	HTTPProxy string `mapstructure:"OPENAI_HTTP_PROXY"`
+
+	// TLSClientCert and TLSClientKey are PEM files presented to the upstream
+	// for mutual TLS.
+	TLSClientCert string `mapstructure:"TLS_CLIENT_CERT"`
+	TLSClientKey  string `mapstructure:"TLS_CLIENT_KEY"`
+	// TLSInsecureSkipVerify disables upstream certificate verification.
+	// Only meant for self-hosted backends with self-signed certificates.
+	TLSInsecureSkipVerify bool `mapstructure:"TLS_INSECURE_SKIP_VERIFY"`
+
+	// RedirectMode selects how upstream redirects are handled, see Redirect*.
+	RedirectMode string `mapstructure:"REDIRECT_MODE"`
+	MaxRedirects int    `mapstructure:"MAX_REDIRECTS"`
+
+	// UpstreamTimeout bounds a whole upstream call on the model routes.
+	// Streaming requests, and requests on other routes, only have to produce
+	// response headers within it. Zero means no limit.
+	UpstreamTimeout time.Duration `mapstructure:"UPSTREAM_TIMEOUT"`
+
+	// RetryAfterMax, when set, adds a Retry-After of a random 1s..max to
+	// 502/503 responses so clients back off from a flapping upstream.
+	RetryAfterMax time.Duration `mapstructure:"RETRY_AFTER_MAX"`
+
+	// ValidateCompletions rejects structurally invalid chat completion
+	// bodies locally instead of forwarding them.
+	ValidateCompletions bool `mapstructure:"VALIDATE_COMPLETIONS"`
+
+	// ResponseCacheTTL caches non-streaming chat completions sent with
+	// temperature 0 for this long. Zero disables the cache.
+	ResponseCacheTTL time.Duration `mapstructure:"RESPONSE_CACHE_TTL"`
+	// ResponseCacheMaxEntries bounds the cache; the oldest entries are
+	// evicted first.
+	ResponseCacheMaxEntries int `mapstructure:"RESPONSE_CACHE_MAX_ENTRIES"`
+
+	// Maintenance starts the proxy rejecting model requests with a 503. It can
+	// be toggled at runtime via /admin/maintenance.
+	Maintenance bool `mapstructure:"MAINTENANCE_MODE"`
+
+	// AdminToken guards the /admin endpoints; they are disabled when empty.
+	AdminToken string `mapstructure:"ADMIN_TOKEN"`
+	// AdminAddr serves the /admin endpoints and /metrics on a separate
+	// listener, e.g. "127.0.0.1:9090", and they return 404 on the main port.
+	// It requires AdminToken. When empty they share the main port.
+	AdminAddr string `mapstructure:"ADMIN_ADDR"`
+	// LogLevel is the initial log level, adjustable via /admin/loglevel.
+	// When neither it nor AdminToken is set, the framework's logger is used
+	// unchanged.
+	LogLevel string `mapstructure:"LOG_LEVEL"`
+
+	// RequestSigner names a scheme used to sign every upstream request after
+	// it has been rewritten, e.g. "sigv4" for Bedrock.
+	RequestSigner string `mapstructure:"REQUEST_SIGNER"`
+
+	AWSAccessKeyID     string `mapstructure:"AWS_ACCESS_KEY_ID"`
+	AWSSecretAccessKey string `mapstructure:"AWS_SECRET_ACCESS_KEY"`
+	AWSSessionToken    string `mapstructure:"AWS_SESSION_TOKEN"`
+	AWSRegion          string `mapstructure:"AWS_REGION"`
+	AWSService         string `mapstructure:"AWS_SERVICE"`
+
+	// Response header rewrite rules. Remove is a comma-separated list of
+	// header names; Set and Add are "Name: value" pairs, one per line, so
+	// values may contain ";" and ",".
+	ResponseHeadersRemove string `mapstructure:"RESPONSE_HEADERS_REMOVE"`
+	ResponseHeadersSet    string `mapstructure:"RESPONSE_HEADERS_SET"`
+	ResponseHeadersAdd    string `mapstructure:"RESPONSE_HEADERS_ADD"`
+
+	// DisabledModels are glob patterns of models that are rejected with a
+	// 403 on every model route and hidden from /v1/models. "*" matches any
+	// run of characters, "/" included, and "?" any single character.
+	DisabledModels       []string `mapstructure:"DISABLED_MODELS"`
+	DisabledModelMessage string   `mapstructure:"DISABLED_MODEL_MESSAGE"`
+
+	// StreamErrorFrame appends an SSE error event to completion streams
+	// that end without the [DONE] sentinel.
+	StreamErrorFrame bool `mapstructure:"STREAM_ERROR_FRAME"`
+
+	// MaxStreamsPerClient caps concurrent streaming requests per client
+	// token (or IP when none is sent). Zero means no limit.
+	MaxStreamsPerClient int `mapstructure:"MAX_STREAMS_PER_CLIENT"`
+
+	// TrustedProxies are the proxy IPs or CIDRs whose X-Forwarded-For is
+	// believed when working out the client IP. None are trusted by default.
+	TrustedProxies []string `mapstructure:"TRUSTED_PROXIES"`
+	// TrustCloudflare takes the client IP from CF-Connecting-IP. Only set it
+	// when the proxy is reachable through Cloudflare alone.
+	TrustCloudflare bool `mapstructure:"TRUST_CLOUDFLARE"`
+
+	// UpstreamLatencyHeader adds X-Upstream-Latency-Ms, the time from
+	// dispatch to the upstream's response headers, to every response.
+	UpstreamLatencyHeader bool `mapstructure:"UPSTREAM_LATENCY_HEADER"`
+
+	// Tracing wraps proxied requests in spans and propagates W3C trace
+	// context upstream. The exporter and sampler are configured by the core
+	// framework's telemetry settings.
+	Tracing bool `mapstructure:"TRACING"`
+
+	// RequestBudget caps model requests sent upstream in any rolling
+	// RequestBudgetWindow; further requests get a 429 until the oldest one
+	// leaves the window. Zero means no budget.
+	RequestBudget       int           `mapstructure:"REQUEST_BUDGET"`
+	RequestBudgetWindow time.Duration `mapstructure:"REQUEST_BUDGET_WINDOW"`
+
+	// ErrorTemplate is a text/template for the body sent when the upstream
+	// can't be reached. It sees .Message, .Type, .Status and .Upstream, and
+	// a json function for quoting. Empty keeps the OpenAI-style default.
+	ErrorTemplate string `mapstructure:"ERROR_TEMPLATE"`
+
+	// EnforceJSON rejects model requests whose Content-Type isn't
+	// application/json (or multipart/form-data on the audio and image
+	// routes) with a 415, and fills in a missing Accept header.
+	EnforceJSON bool `mapstructure:"ENFORCE_JSON_CONTENT_TYPE"`
+
+	// MaxBodyBytes bounds the request bodies the proxy reads into memory to
+	// inspect; larger ones get a 413. Multipart uploads are only read when
+	// DisabledModels needs their model, and otherwise stream through
+	// unlimited.
+	MaxBodyBytes int64 `mapstructure:"MAX_BODY_BYTES"`
+
+	// ModelDefaultParams is a JSON object from model glob patterns, matched
+	// like DisabledModels, to params filled into chat completions that leave
+	// them out, e.g. {"gpt-4o*": {"temperature": 0.7}}. Where patterns
+	// overlap, the longest matching pattern wins. Params the client sends
+	// are never changed.
+	ModelDefaultParams string `mapstructure:"MODEL_DEFAULT_PARAMS"`
+
+	// ModelMaxTokens is a JSON object from model glob patterns, matched like
+	// DisabledModels, to caps on max_tokens and max_completion_tokens, e.g.
+	// {"gpt-4o*": 4096}. Chat completions that ask for more are lowered to
+	// the cap of the longest matching pattern.
+	ModelMaxTokens string `mapstructure:"MODEL_MAX_TOKENS"`
+
+	// ModelSystemPrompts is a JSON object from model glob patterns, matched
+	// like DisabledModels, to a system prompt for chat completions, e.g.
+	// {"gpt-4o*": "Answer in English."}. It is merged into the client's
+	// first system message, or sent as a new one, unless the client already
+	// sent it. The longest matching pattern wins.
+	ModelSystemPrompts string `mapstructure:"MODEL_SYSTEM_PROMPTS"`
+
+	// ModelAlias is a model name clients can send to get ModelAliasTarget
+	// instead. The alias is only active when a target is set, and is listed
+	// in /v1/models as owned by ModelAliasOwnedBy unless the target is
+	// disabled.
+	ModelAlias        string `mapstructure:"MODEL_ALIAS"`
+	ModelAliasTarget  string `mapstructure:"MODEL_ALIAS_TARGET"`
+	ModelAliasOwnedBy string `mapstructure:"MODEL_ALIAS_OWNED_BY"`
+}
+
+// ModelDisabled reports whether model matches one of DisabledModels.
+func (c *Config) ModelDisabled(model string) bool {
+	for _, pattern := range c.DisabledModels {
+		if matchModel(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchModel reports whether model matches the glob pattern. Unlike
+// path.Match, "*" also matches "/", so "*claude*" covers vendor-prefixed
+// names such as "anthropic/claude-3".
+func matchModel(patternStr, modelStr string) bool {
+	pattern, model := []rune(patternStr), []rune(modelStr)
+	// Backtrack to just after the last "*" on a mismatch.
+	p, m := 0, 0
+	star, next := -1, 0
+	for m < len(model) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == model[m]):
+			p++
+			m++
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, m
+			p++
+		case star >= 0:
+			next++
+			p, m = star+1, next
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// DefaultParams maps model glob patterns to default request params.
+type DefaultParams map[string]map[string]json.RawMessage
+
+// DefaultParams parses MODEL_DEFAULT_PARAMS.
+func (c *Config) DefaultParams() (DefaultParams, error) {
+	if c.ModelDefaultParams == "" {
+		return nil, nil
+	}
+	var d DefaultParams
+	if err := json.Unmarshal([]byte(c.ModelDefaultParams), &d); err != nil {
+		return nil, fmt.Errorf("MODEL_DEFAULT_PARAMS: %w", err)
+	}
+	return d, nil
+}
+
+// For returns the defaults that apply to model.
+func (d DefaultParams) For(model string) map[string]json.RawMessage {
+	params := make(map[string]json.RawMessage)
+	for _, pattern := range matchingPatterns(d, model) {
+		for k, v := range d[pattern] {
+			if _, ok := params[k]; !ok {
+				params[k] = v
+			}
+		}
+	}
+	return params
+}
+
+// MaxTokens maps model glob patterns to max_tokens caps.
+type MaxTokens map[string]int
+
+// MaxTokens parses MODEL_MAX_TOKENS.
+func (c *Config) MaxTokens() (MaxTokens, error) {
+	if c.ModelMaxTokens == "" {
+		return nil, nil
+	}
+	var m MaxTokens
+	if err := json.Unmarshal([]byte(c.ModelMaxTokens), &m); err != nil {
+		return nil, fmt.Errorf("MODEL_MAX_TOKENS: %w", err)
+	}
+	for pattern, limit := range m {
+		if limit <= 0 {
+			return nil, fmt.Errorf("MODEL_MAX_TOKENS: cap for %q must be positive", pattern)
+		}
+	}
+	return m, nil
+}
+
+// For returns the cap that applies to model, or 0 when none does.
+func (m MaxTokens) For(model string) int {
+	if patterns := matchingPatterns(m, model); len(patterns) > 0 {
+		return m[patterns[0]]
+	}
+	return 0
+}
+
+// SystemPrompts maps model glob patterns to system prompts.
+type SystemPrompts map[string]string
+
+// SystemPrompts parses MODEL_SYSTEM_PROMPTS.
+func (c *Config) SystemPrompts() (SystemPrompts, error) {
+	if c.ModelSystemPrompts == "" {
+		return nil, nil
+	}
+	var p SystemPrompts
+	if err := json.Unmarshal([]byte(c.ModelSystemPrompts), &p); err != nil {
+		return nil, fmt.Errorf("MODEL_SYSTEM_PROMPTS: %w", err)
+	}
+	return p, nil
+}
+
+// For returns the system prompt for model, or "" when none applies.
+func (p SystemPrompts) For(model string) string {
+	if patterns := matchingPatterns(p, model); len(patterns) > 0 {
+		return p[patterns[0]]
+	}
+	return ""
+}
+
+// matchingPatterns returns the patterns in m that match model, longest
+// first.
+func matchingPatterns[V any](m map[string]V, model string) []string {
+	var patterns []string
+	for pattern := range m {
+		if matchModel(pattern, model) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	return patterns
+}
+
+// HeaderRules rewrites headers: Remove is applied first, then Set replaces
+// and Add appends values.
+type HeaderRules struct {
+	Remove []string
+	Set    http.Header
+	Add    http.Header
+}
+
+// ResponseHeaderRules parses the RESPONSE_HEADERS_* settings.
+func (c *Config) ResponseHeaderRules() (HeaderRules, error) {
+	var rules HeaderRules
+	for _, name := range strings.Split(c.ResponseHeadersRemove, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			rules.Remove = append(rules.Remove, name)
+		}
+	}
+	var err error
+	if rules.Set, err = parseHeaderList(c.ResponseHeadersSet); err != nil {
+		return rules, fmt.Errorf("RESPONSE_HEADERS_SET: %w", err)
+	}
+	if rules.Add, err = parseHeaderList(c.ResponseHeadersAdd); err != nil {
+		return rules, fmt.Errorf("RESPONSE_HEADERS_ADD: %w", err)
+	}
+	return rules, nil
+}
+
+func parseHeaderList(s string) (http.Header, error) {
+	h := make(http.Header)
+	for _, pair := range strings.Split(s, "\n") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q, want \"Name: value\"", pair)
+		}
+		h.Add(name, strings.TrimSpace(value))
+	}
+	return h, nil
 }
 
 func New() (*Config, error) {
@@ -32,6 +418,177 @@ func New() (*Config, error) {
 	if config.OpenAIEndpoint == "" {
 		config.OpenAIEndpoint = defaultEndpoint
 	}
+	if strings.HasPrefix(config.OpenAIEndpoint, unixScheme) {
+		config.UnixSocket = strings.TrimPrefix(config.OpenAIEndpoint, unixScheme)
+		if config.UnixHost == "" {
+			config.UnixHost = defaultUnixHost
+		}
+		config.OpenAIEndpoint = config.UnixHost
+	}
 	config.OpenAIEndpoint = strings.TrimSuffix(config.OpenAIEndpoint, "/")
+	if config.UserAgent == "" {
+		config.UserAgent = "openai-proxy/" + version.Version
+	}
+	if config.AuthStyle == "" {
+		config.AuthStyle = AuthStyleBearer
+	}
+	if config.RequestSigner == SignerSigV4 && config.AWSService == "" {
+		config.AWSService = defaultAWSService
+	}
+	if config.ModelAlias == "" {
+		config.ModelAlias = defaultModelAlias
+	}
+	if config.ModelAliasOwnedBy == "" {
+		config.ModelAliasOwnedBy = defaultModelAliasOwnedBy
+	}
+	if config.DisabledModelMessage == "" {
+		config.DisabledModelMessage = defaultDisabledModelMessage
+	}
+	if config.RequestBudget > 0 && config.RequestBudgetWindow <= 0 {
+		config.RequestBudgetWindow = defaultBudgetWindow
+	}
+	if config.MaxRedirects <= 0 {
+		config.MaxRedirects = defaultMaxRedirects
+	}
+	if config.ResponseCacheMaxEntries <= 0 {
+		config.ResponseCacheMaxEntries = defaultCacheEntries
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 	return &config, nil
 }
+
+// Masked returns a copy of the config with secrets masked.
+func (c *Config) Masked() Config {
+	masked := *c
+	masked.OpenAIKey = redact.Key(c.OpenAIKey)
+	masked.AdminToken = redact.Key(c.AdminToken)
+	masked.AWSSecretAccessKey = redact.Key(c.AWSSecretAccessKey)
+	masked.AWSSessionToken = redact.Key(c.AWSSessionToken)
+	if u, err := url.Parse(c.HTTPProxy); err == nil {
+		masked.HTTPProxy = u.Redacted()
+	}
+	return masked
+}
+
+// LogValue masks secrets when the config is logged.
+func (c *Config) LogValue() slog.Value {
+	return slog.AnyValue(c.Masked())
+}
+
+// Secrets returns the configured secrets, for scrubbing them from output.
+func (c *Config) Secrets() []string {
+	return []string{c.OpenAIKey, c.AdminToken, c.AWSSecretAccessKey, c.AWSSessionToken, c.proxyPassword()}
+}
+
+// proxyPassword returns the password in HTTPProxy's userinfo, if any.
+func (c *Config) proxyPassword() string {
+	u, err := url.Parse(c.HTTPProxy)
+	if err != nil || u.User == nil {
+		return ""
+	}
+	password, _ := u.User.Password()
+	return password
+}
+
+// ErrorData is what ERROR_TEMPLATE is executed with.
+type ErrorData struct {
+	Message  string
+	Type     string
+	Status   int
+	Upstream string
+}
+
+// ErrorTemplate parses an ERROR_TEMPLATE and checks that it executes. It
+// returns nil for an empty template.
+func ErrorTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("error").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid error template: %w", err)
+	}
+	sample := ErrorData{Message: "sample", Type: "proxy_error", Status: http.StatusBadGateway}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("invalid error template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Validate reports the first setting that can't be used as configured.
+func (c *Config) Validate() error {
+	if _, err := url.Parse(c.OpenAIEndpoint); err != nil {
+		return fmt.Errorf("invalid openai endpoint: %w", err)
+	}
+	switch c.AuthStyle {
+	case AuthStyleBearer, AuthStyleXAPIKey, AuthStyleAPIKeyQuery:
+	default:
+		return fmt.Errorf("unknown auth style %q", c.AuthStyle)
+	}
+	switch c.RedirectMode {
+	case RedirectPass, RedirectFollow, RedirectRewrite:
+	default:
+		return fmt.Errorf("unknown redirect mode %q", c.RedirectMode)
+	}
+	switch c.RequestSigner {
+	case SignerNone:
+	case SignerSigV4:
+		if c.AWSAccessKeyID == "" || c.AWSSecretAccessKey == "" || c.AWSRegion == "" {
+			return fmt.Errorf("sigv4 signing requires AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_REGION")
+		}
+	default:
+		return fmt.Errorf("unknown request signer %q", c.RequestSigner)
+	}
+	if _, err := ErrorTemplate(c.ErrorTemplate); err != nil {
+		return err
+	}
+	if _, err := c.ResponseHeaderRules(); err != nil {
+		return err
+	}
+	if _, err := c.DefaultParams(); err != nil {
+		return err
+	}
+	if _, err := c.MaxTokens(); err != nil {
+		return err
+	}
+	if _, err := c.SystemPrompts(); err != nil {
+		return err
+	}
+	if c.LogLevel != "" {
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(c.LogLevel)); err != nil {
+			return fmt.Errorf("invalid log level: %w", err)
+		}
+	}
+	if c.AdminAddr != "" && c.AdminToken == "" {
+		return fmt.Errorf("ADMIN_ADDR requires ADMIN_TOKEN")
+	}
+	if (c.TLSClientCert == "") != (c.TLSClientKey == "") {
+		return fmt.Errorf("TLS_CLIENT_CERT and TLS_CLIENT_KEY must be set together")
+	}
+	if c.HTTPProxy != "" {
+		u, err := url.Parse(c.HTTPProxy)
+		if err != nil {
+			// The *url.Error would repeat the URL, password included.
+			var uerr *url.Error
+			if errors.As(err, &uerr) {
+				err = uerr.Err
+			}
+			return fmt.Errorf("invalid http proxy: %w", err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid http proxy %q: scheme and host are required", u.Redacted())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestResponseHeaderRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    Config
+		want    HeaderRules
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			want: HeaderRules{Set: http.Header{}, Add: http.Header{}},
+		},
+		{
+			name: "remove",
+			conf: Config{ResponseHeadersRemove: "Openai-Organization, x-request-id,"},
+			want: HeaderRules{
+				Remove: []string{"Openai-Organization", "x-request-id"},
+				Set:    http.Header{},
+				Add:    http.Header{},
+			},
+		},
+		{
+			name: "values keep semicolons and commas",
+			conf: Config{
+				ResponseHeadersSet: "Cache-Control: no-store, max-age=0\nContent-Type: application/json; charset=utf-8",
+				ResponseHeadersAdd: "Link: <https://example.com>; rel=preload\n\nVia: proxy\r\n",
+			},
+			want: HeaderRules{
+				Set: http.Header{
+					"Cache-Control": {"no-store, max-age=0"},
+					"Content-Type":  {"application/json; charset=utf-8"},
+				},
+				Add: http.Header{
+					"Link": {"<https://example.com>; rel=preload"},
+					"Via":  {"proxy"},
+				},
+			},
+		},
+		{
+			name:    "missing colon",
+			conf:    Config{ResponseHeadersSet: "X-Test"},
+			wantErr: true,
+		},
+		{
+			name:    "missing name",
+			conf:    Config{ResponseHeadersAdd: ": value"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.conf.ResponseHeaderRules()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rules = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultParamsFor(t *testing.T) {
+	conf := Config{ModelDefaultParams: `{
+		"gpt-4o*": {"temperature": 0.7, "top_p": 1},
+		"gpt-4o-mini*": {"temperature": 0.2},
+		"o*": {"reasoning_effort": "low"},
+		"*llama*": {"top_p": 0.9}
+	}`}
+	d, err := conf.DefaultParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		model string
+		want  map[string]string
+	}{
+		{"gpt-4o", map[string]string{"temperature": "0.7", "top_p": "1"}},
+		{"gpt-4o-mini", map[string]string{"temperature": "0.2", "top_p": "1"}},
+		{"o3", map[string]string{"reasoning_effort": `"low"`}},
+		{"meta/llama-3", map[string]string{"top_p": "0.9"}},
+		{"gpt-3.5-turbo", map[string]string{}},
+	}
+	for _, tt := range tests {
+		got := make(map[string]string)
+		for k, v := range d.For(tt.model) {
+			got[k] = string(v)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("For(%q) = %v, want %v", tt.model, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{`[1]`, `{"gpt-4o": 1}`} {
+		if _, err := (&Config{ModelDefaultParams: bad}).DefaultParams(); err == nil {
+			t.Errorf("DefaultParams(%s) succeeded", bad)
+		}
+	}
+}
+
+func TestModelDisabled(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		model    string
+		want     bool
+	}{
+		{[]string{"*"}, "meta/llama-3", true},
+		{[]string{"*claude*"}, "anthropic/claude-3", true},
+		{[]string{"gpt-4?"}, "gpt-4o", true},
+		{[]string{"gpt-4?"}, "gpt-4o-mini", false},
+		{[]string{"gpt-4*-mini"}, "gpt-4o-mini", true},
+		{[]string{"o1*", "dall-e-*"}, "dall-e-3", true},
+		{[]string{"o1*"}, "gpt-4o", false},
+		{[]string{"a*b*c"}, "aXbYbZc", true},
+		{[]string{"a*b*c"}, "aXbYc-d", false},
+		{nil, "gpt-4o", false},
+	}
+	for _, tt := range tests {
+		c := &Config{DisabledModels: tt.patterns}
+		if got := c.ModelDisabled(tt.model); got != tt.want {
+			t.Errorf("%q.ModelDisabled(%q) = %v, want %v", tt.patterns, tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestMaxTokensFor(t *testing.T) {
+	conf := Config{ModelMaxTokens: `{"gpt-4o*": 4096, "gpt-4o-mini*": 1024, "*llama*": 2048}`}
+	m, err := conf.MaxTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		model string
+		want  int
+	}{
+		{"gpt-4o", 4096},
+		{"gpt-4o-mini", 1024},
+		{"meta/llama-3", 2048},
+		{"o3", 0},
+	}
+	for _, tt := range tests {
+		if got := m.For(tt.model); got != tt.want {
+			t.Errorf("For(%q) = %d, want %d", tt.model, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{`[1]`, `{"gpt-4o": "big"}`, `{"gpt-4o": 0}`} {
+		if _, err := (&Config{ModelMaxTokens: bad}).MaxTokens(); err == nil {
+			t.Errorf("MaxTokens(%s) succeeded", bad)
+		}
+	}
+}
+
+func TestValidateAdminAddr(t *testing.T) {
+	if err := (&Config{AdminAddr: "127.0.0.1:9090", AuthStyle: AuthStyleBearer}).Validate(); err == nil {
+		t.Error("ADMIN_ADDR without ADMIN_TOKEN validated")
+	}
+	if err := (&Config{AdminAddr: "127.0.0.1:9090", AdminToken: "admin-token", AuthStyle: AuthStyleBearer}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
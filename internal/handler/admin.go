@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orvice/openapi-proxy/internal/config"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// serveAdmin registers the admin endpoints on r, or, when conf.AdminAddr is
+// set, serves them and /metrics on their own listener. In that case /admin
+// and /metrics on r answer 404 so they never fall through to the upstream.
+// It fails if the admin listener can't be bound.
+func serveAdmin(r *gin.Engine, conf *config.Config) error {
+	if conf.AdminAddr == "" {
+		adminRouter(r, conf)
+		return nil
+	}
+	r.Any("/admin", adminNotFound)
+	r.Any("/admin/*path", adminNotFound)
+	if hasRoute(r, "/metrics") {
+		slog.Warn("/metrics is registered by the framework and stays on the main port")
+	} else {
+		r.Any("/metrics", adminNotFound)
+	}
+
+	e := gin.New()
+	e.Use(gin.Recovery())
+	e.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	adminRouter(e, conf)
+	ln, err := net.Listen("tcp", conf.AdminAddr)
+	if err != nil {
+		return fmt.Errorf("admin listener: %w", err)
+	}
+	slog.Info("admin listener started", "addr", ln.Addr().String())
+	go func() {
+		if err := http.Serve(ln, e); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("admin listener error", "error", err)
+		}
+	}()
+	return nil
+}
+
+// hasRoute reports whether r already serves path.
+func hasRoute(r *gin.Engine, path string) bool {
+	for _, route := range r.Routes() {
+		if route.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// adminRouter registers the /admin endpoints, guarded by conf.AdminToken.
+// Nothing is registered when no token is configured.
+func adminRouter(r gin.IRouter, conf *config.Config) {
+	if conf.AdminToken == "" {
+		return
+	}
+	g := r.Group("/admin", adminAuth(conf.AdminToken))
+	g.GET("/loglevel", GetLogLevel)
+	g.POST("/loglevel", SetLogLevel)
+	g.POST("/probe", Probe)
+	g.POST("/compare", Compare)
+	g.GET("/config", Config)
+	g.GET("/debug/state", DebugState)
+	g.GET("/maintenance", GetMaintenance)
+	g.POST("/maintenance", SetMaintenance)
+}
+
+func adminNotFound(c *gin.Context) {
+	writeError(c.Writer, http.StatusNotFound, "invalid_request_error", "admin endpoints are not served on this port")
+}
+
+// adminTokenHeader carries the admin token on proxied requests, where
+// Authorization already holds the upstream key.
+const adminTokenHeader = "X-Admin-Token"
+
+// hasAdminToken reports whether a proxied request carries the admin token.
+func hasAdminToken(c *gin.Context, token string) bool {
+	got := c.GetHeader(adminTokenHeader)
+	return token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+func adminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		got := strings.TrimPrefix(c.GetHeader(authHeader), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+func TestServeAdminSeparateListener(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	conf := &config.Config{AdminAddr: taken.Addr().String(), AdminToken: "admin-token"}
+	if err := serveAdmin(gin.New(), conf); err == nil {
+		t.Error("serveAdmin succeeded on a port already in use")
+	}
+
+	r := gin.New()
+	conf = &config.Config{AdminAddr: "127.0.0.1:0", AdminToken: "admin-token"}
+	if err := serveAdmin(r, conf); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/metrics", "/admin/config"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("main port %s = %d, want %d", path, w.Code, http.StatusNotFound)
+		}
+	}
+}
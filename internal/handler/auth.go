@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+const (
+	apiKeyHeader     = "x-api-key"
+	apiKeyQueryParam = "key"
+)
+
+// requestKey returns the key the client sent, in the configured auth style
+// or as a bearer token, or the configured default key when there is none.
+func requestKey(req *http.Request, conf *config.Config) string {
+	key := requestKeyFrom(req, conf.AuthStyle)
+	if key == "" {
+		key = bearerToken(req.Header.Get(authHeader))
+	}
+	//  chekc if key is empty
+	if key == "" {
+		slog.Info("no token found, using default token")
+		return conf.OpenAIKey
+	}
+
+	slog.Info("token found in request")
+	if key == "null" || strings.Contains(key, "null") {
+		slog.Info(" token is null, using default token")
+		return conf.OpenAIKey
+	}
+	return key
+}
+
+// requestKeyFrom reads the key from where the given auth style puts it.
+func requestKeyFrom(req *http.Request, style string) string {
+	switch style {
+	case config.AuthStyleXAPIKey:
+		return req.Header.Get(apiKeyHeader)
+	case config.AuthStyleAPIKeyQuery:
+		return req.URL.Query().Get(apiKeyQueryParam)
+	default:
+		return bearerToken(req.Header.Get(authHeader))
+	}
+}
+
+// bearerToken returns the token of a Bearer authorization value, or "" for
+// any other scheme.
+func bearerToken(auth string) string {
+	scheme, token, ok := strings.Cut(auth, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// passthroughAuth reports whether the client sent an Authorization scheme
+// other than Bearer, such as Basic, which is forwarded unchanged.
+func passthroughAuth(req *http.Request) bool {
+	auth := req.Header.Get(authHeader)
+	return auth != "" && bearerToken(auth) == ""
+}
+
+// upstreamCredential returns the credential the request reaches the upstream
+// with: a passed-through Authorization value, or the resolved key.
+func upstreamCredential(req *http.Request, conf *config.Config) string {
+	if passthroughAuth(req) {
+		return req.Header.Get(authHeader)
+	}
+	return requestKey(req, conf)
+}
+
+// setAuth presents key to the upstream in the given auth style, removing any
+// credentials the client sent in another form. An empty key sends none.
+func setAuth(req *http.Request, style, key string) {
+	req.Header.Del(authHeader)
+	req.Header.Del(apiKeyHeader)
+	if key == "" {
+		return
+	}
+
+	switch style {
+	case config.AuthStyleXAPIKey:
+		req.Header.Set(apiKeyHeader, key)
+	case config.AuthStyleAPIKeyQuery:
+		q := req.URL.Query()
+		q.Set(apiKeyQueryParam, key)
+		req.URL.RawQuery = q.Encode()
+	default:
+		req.Header.Set(authHeader, "Bearer "+key)
+	}
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	bodyKey             = "openai-proxy/body"
+	resolvedModelHeader = "X-Resolved-Model"
+)
+
+type resolvedModelKey struct{}
+
+// jsonBody returns the body of a request that may carry JSON. Multipart
+// uploads aren't read and return nil; bodyModel reads those when a feature
+// needs their model.
+func jsonBody(c *gin.Context) ([]byte, error) {
+	if isMultipart(c) {
+		return nil, nil
+	}
+	return requestBody(c)
+}
+
+// bufferedBody returns the body if a middleware has already read it.
+func bufferedBody(c *gin.Context) ([]byte, bool) {
+	v, ok := c.Get(bodyKey)
+	if !ok {
+		return nil, false
+	}
+	return v.([]byte), true
+}
+
+// isMultipart reports whether the request is a multipart upload.
+func isMultipart(c *gin.Context) bool {
+	mt, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	return mt == "multipart/form-data"
+}
+
+// requestBody is peekBody memoized on the gin context, so middlewares and the
+// proxy handler read the body only once. A body over MaxBodyBytes is
+// answered with a 413 and the request aborted.
+func requestBody(c *gin.Context) ([]byte, error) {
+	if v, ok := c.Get(bodyKey); ok {
+		body := v.([]byte)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		return body, nil
+	}
+	body, err := peekBody(c.Request, proxyConf.MaxBodyBytes)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(c.Writer, http.StatusRequestEntityTooLarge, "invalid_request_error",
+				fmt.Sprintf("request body is larger than %d bytes", tooLarge.Limit))
+			c.Abort()
+		}
+		return nil, err
+	}
+	c.Set(bodyKey, body)
+	return body, nil
+}
+
+// peekBody returns the request body, read up to limit bytes when limit is
+// positive, and puts a fresh copy back on the request so it can still be
+// proxied. The body is read whatever its Content-Type, since the upstream
+// parses JSON bodies however they are labelled.
+func peekBody(req *http.Request, limit int64) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	r := req.Body
+	if limit > 0 {
+		r = http.MaxBytesReader(nil, req.Body, limit)
+	}
+	body, err := io.ReadAll(r)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// setJSONBody replaces the request body after it has been rewritten.
+func setJSONBody(c *gin.Context, body []byte) {
+	c.Set(bodyKey, body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Request.ContentLength = int64(len(body))
+	c.Request.Header.Set("Content-Length", strconv.Itoa(len(body)))
+}
+
+// isStreaming reports whether a JSON request body asks for a streamed
+// response.
+func isStreaming(body []byte) bool {
+	var r struct {
+		Stream bool `json:"stream"`
+	}
+	return json.Unmarshal(body, &r) == nil && r.Stream
+}
+
+// requestModel returns the model named in a JSON request body.
+func requestModel(body []byte) string {
+	var r struct {
+		Model string `json:"model"`
+	}
+	if json.Unmarshal(body, &r) != nil {
+		return ""
+	}
+	return r.Model
+}
+
+// bodyModel returns the model a request names, from its JSON body or, for
+// uploads, its multipart "model" field. It fails when the body can't be
+// parsed, so model checks can't be skipped by sending something else.
+func bodyModel(c *gin.Context) (string, error) {
+	body, err := requestBody(c)
+	if err != nil || len(body) == 0 {
+		return "", err
+	}
+	mt, params, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mt == "multipart/form-data" {
+		return formModel(body, params["boundary"])
+	}
+	var r struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return "", fmt.Errorf("invalid request body: %w", err)
+	}
+	return r.Model, nil
+}
+
+// formModel reads the "model" field of a multipart form.
+func formModel(body []byte, boundary string) (string, error) {
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid multipart body: %w", err)
+		}
+		if part.FormName() == "model" {
+			model, err := io.ReadAll(io.LimitReader(part, 256))
+			return strings.TrimSpace(string(model)), err
+		}
+	}
+}
+
+// withResolvedModel records the model that will be sent upstream, so it can
+// be echoed back in the X-Resolved-Model response header.
+func withResolvedModel(req *http.Request, model string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), resolvedModelKey{}, model))
+}
+
+func resolvedModel(req *http.Request) string {
+	if req == nil {
+		return ""
+	}
+	model, _ := req.Context().Value(resolvedModelKey{}).(string)
+	return model
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+func TestRequestBodyLimits(t *testing.T) {
+	defer func(conf *config.Config) { proxyConf = conf }(proxyConf)
+	proxyConf = &config.Config{
+		MaxBodyBytes:         512,
+		DisabledModels:       []string{"whisper-*"},
+		DisabledModelMessage: "disabled",
+	}
+
+	var upload bytes.Buffer
+	mw := multipart.NewWriter(&upload)
+	mw.WriteField("model", "whisper-1")
+	mw.WriteField("file", "small")
+	mw.Close()
+
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		handler     gin.HandlerFunc
+		contentType string
+		body        string
+		wantStatus  int
+		wantRead    bool
+	}{
+		{"json over the limit", rejectDisabledModel, "application/json",
+			`{"model":"gpt-4o","input":"` + strings.Repeat("x", 1000) + `"}`, http.StatusRequestEntityTooLarge, false},
+		{"json under the limit", rejectDisabledModel, "application/json",
+			`{"model":"gpt-4o"}`, http.StatusOK, true},
+		{"upload with a disabled model", rejectDisabledModel, mw.FormDataContentType(),
+			upload.String(), http.StatusForbidden, true},
+		{"upload without a model check", func(c *gin.Context) { jsonBody(c) }, mw.FormDataContentType(),
+			upload.String(), http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			read := false
+			r := gin.New()
+			r.POST("/v1/audio/*path", tt.handler, func(c *gin.Context) {
+				_, read = c.Get(bodyKey)
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if read != tt.wantRead && w.Code == http.StatusOK {
+				t.Errorf("body buffered = %v, want %v", read, tt.wantRead)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestBudget counts upstream model requests over a rolling window:
+// each request uses up budget for one window after it was let through.
+type requestBudget struct {
+	limit  int
+	window time.Duration
+
+	mu   sync.Mutex
+	sent []time.Time // oldest first, at most limit long
+}
+
+func newRequestBudget(limit int, window time.Duration) *requestBudget {
+	return &requestBudget{limit: limit, window: window}
+}
+
+// expire forgets requests that have left the window. Callers hold mu.
+func (b *requestBudget) expire(now time.Time) {
+	i := 0
+	for i < len(b.sent) && now.Sub(b.sent[i]) >= b.window {
+		i++
+	}
+	b.sent = b.sent[i:]
+}
+
+func (b *requestBudget) take() (ok bool, retry time.Time) {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire(now)
+	if len(b.sent) >= b.limit {
+		return false, b.sent[0].Add(b.window)
+	}
+	b.sent = append(b.sent, now)
+	return true, time.Time{}
+}
+
+// stats reports the remaining budget, for the debug and health endpoints.
+func (b *requestBudget) stats() gin.H {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire(now)
+	h := gin.H{
+		"limit":     b.limit,
+		"window":    b.window.String(),
+		"remaining": b.limit - len(b.sent),
+	}
+	if len(b.sent) > 0 {
+		h["next_release"] = b.sent[0].Add(b.window)
+	}
+	return h
+}
+
+// enforce rejects requests once the budget for the window is spent.
+func (b *requestBudget) enforce(c *gin.Context) {
+	ok, retry := b.take()
+	if ok {
+		return
+	}
+	c.Header("Retry-After", strconv.Itoa(int(time.Until(retry).Seconds())+1))
+	writeError(c.Writer, http.StatusTooManyRequests, "rate_limit_error", "request budget exhausted")
+	c.Abort()
+}
+
+// Healthz reports that the proxy is up, with the remaining request budget
+// when one is configured.
+func Healthz(c *gin.Context) {
+	h := gin.H{"status": "ok"}
+	if budget != nil {
+		h["budget"] = budget.stats()
+	}
+	c.JSON(http.StatusOK, h)
+}
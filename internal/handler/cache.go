@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type cacheKeyCtx struct{}
+
+type cacheEntry struct {
+	key     string
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// responseCache holds completed upstream responses for deterministic
+// requests, keyed by a hash of the normalized request. Entries share one
+// TTL, so the insertion order kept in order is also their expiry order.
+type responseCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // oldest at the front
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func newResponseCache(ttl time.Duration, maxEntries int) *responseCache {
+	return &responseCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+func (rc *responseCache) get(key string) (*cacheEntry, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	el, ok := rc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expires) {
+		rc.remove(el)
+		return nil, false
+	}
+	return e, true
+}
+
+func (rc *responseCache) set(key string, header http.Header, body []byte) {
+	now := time.Now()
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if el, ok := rc.entries[key]; ok {
+		rc.remove(el)
+	}
+	rc.entries[key] = rc.order.PushBack(&cacheEntry{key: key, header: header, body: body, expires: now.Add(rc.ttl)})
+	for el := rc.order.Front(); el != nil; el = rc.order.Front() {
+		if len(rc.entries) <= rc.maxEntries && !now.After(el.Value.(*cacheEntry).expires) {
+			break
+		}
+		rc.remove(el)
+		cacheEvictions.Inc()
+	}
+	cacheEntries.Set(float64(len(rc.entries)))
+}
+
+// remove drops el; the caller holds rc.mu.
+func (rc *responseCache) remove(el *list.Element) {
+	rc.order.Remove(el)
+	delete(rc.entries, el.Value.(*cacheEntry).key)
+	cacheEntries.Set(float64(len(rc.entries)))
+}
+
+// stats summarizes the cache for the debug endpoint.
+func (rc *responseCache) stats() gin.H {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	var oldest time.Duration
+	if el := rc.order.Front(); el != nil {
+		oldest = time.Since(el.Value.(*cacheEntry).expires.Add(-rc.ttl))
+	}
+	return gin.H{
+		"entries":     len(rc.entries),
+		"max_entries": rc.maxEntries,
+		"oldest_age":  oldest.String(),
+		"hits":        rc.hits.Load(),
+		"misses":      rc.misses.Load(),
+		"ttl":         rc.ttl.String(),
+	}
+}
+
+// completionCacheKey returns the cache key for a chat completion body sent
+// with the given upstream credential, or "" when the request is not
+// deterministic enough to cache.
+func completionCacheKey(body []byte, credential string) string {
+	var req map[string]any
+	if err := json.Unmarshal(body, &req); err != nil {
+		return ""
+	}
+	if stream, _ := req["stream"].(bool); stream {
+		return ""
+	}
+	if t, ok := req["temperature"].(float64); !ok || t != 0 {
+		return ""
+	}
+	// Re-marshalling sorts the keys, so equivalent bodies hash the same.
+	normalized, err := json.Marshal(req)
+	if err != nil {
+		return ""
+	}
+	h := sha256.New()
+	h.Write([]byte(credential))
+	h.Write([]byte{0})
+	h.Write(normalized)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// serve answers from the cache when possible, and otherwise marks the
+// request so storeResponse caches the upstream reply. Requests sent to
+// another upstream via X-Upstream-Host bypass the cache entirely.
+func (rc *responseCache) serve(c *gin.Context) {
+	if c.GetHeader(upstreamHostHeader) != "" {
+		return
+	}
+	body, err := jsonBody(c)
+	if err != nil || body == nil || c.Request.Method != http.MethodPost {
+		return
+	}
+	key := completionCacheKey(body, upstreamCredential(c.Request, proxyConf))
+	if key == "" {
+		return
+	}
+
+	if e, ok := rc.get(key); ok {
+		rc.hits.Add(1)
+		cacheRequests.WithLabelValues("hit").Inc()
+		slog.Debug("response cache hit", "key", key[:12])
+		for k, v := range e.header {
+			c.Writer.Header()[k] = v
+		}
+		c.Header("X-Proxy-Cache", "HIT")
+		c.Data(http.StatusOK, e.header.Get("Content-Type"), e.body)
+		c.Abort()
+		return
+	}
+
+	rc.misses.Add(1)
+	cacheRequests.WithLabelValues("miss").Inc()
+	c.Header("X-Proxy-Cache", "MISS")
+	// Ask for an uncompressed reply so it can be served to any client.
+	c.Request.Header.Del("Accept-Encoding")
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), cacheKeyCtx{}, key))
+}
+
+// storeResponse caches a successful upstream reply to a marked request.
+func (rc *responseCache) storeResponse(resp *http.Response) error {
+	key, _ := resp.Request.Context().Value(cacheKeyCtx{}).(string)
+	if key == "" || resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	header := make(http.Header)
+	for _, k := range []string{"Content-Type", "Openai-Model", "Openai-Organization"} {
+		if v := resp.Header.Get(k); v != "" {
+			header.Set(k, v)
+		}
+	}
+	rc.set(key, header, body)
+
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	resp.ContentLength = int64(len(body))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
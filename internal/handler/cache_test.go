@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+func TestResponseCacheKeyedByCredential(t *testing.T) {
+	defer func(conf *config.Config) { proxyConf = conf }(proxyConf)
+	proxyConf = &config.Config{AuthStyle: config.AuthStyleXAPIKey, OpenAIKey: "default-key"}
+
+	gin.SetMode(gin.TestMode)
+	rc := newResponseCache(time.Minute, 10)
+	r := gin.New()
+	r.POST("/v1/chat/completions", rc.serve, func(c *gin.Context) {
+		// Stand in for the upstream, caching the reply like storeResponse.
+		key, _ := c.Request.Context().Value(cacheKeyCtx{}).(string)
+		rc.set(key, http.Header{"Content-Type": {"application/json"}}, []byte(`{"id":"paid"}`))
+		c.Data(http.StatusOK, "application/json", []byte(`{"id":"paid"}`))
+	})
+
+	tests := []struct {
+		name   string
+		apiKey string
+		want   string
+	}{
+		{"first request", "valid-key", "MISS"},
+		{"same key", "valid-key", "HIT"},
+		{"other key", "attacker-bogus", "MISS"},
+		{"no key uses the default", "", "MISS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+				strings.NewReader(`{"model":"gpt-4o","temperature":0,"messages":[]}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.apiKey != "" {
+				req.Header.Set(apiKeyHeader, tt.apiKey)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if got := w.Header().Get("X-Proxy-Cache"); got != tt.want {
+				t.Errorf("X-Proxy-Cache = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"text/tabwriter"
+	"time"
+
+	"github.com/orvice/openapi-proxy/internal/config"
+	"github.com/orvice/openapi-proxy/internal/redact"
+)
+
+const checkTimeout = 10 * time.Second
+
+// Check loads and validates the config, then lists models on the upstream
+// with the configured key. It writes a result table to w and returns an
+// error if any step failed.
+func Check(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+	fmt.Fprintln(tw, "CHECK\tRESULT\tDETAIL")
+
+	conf, err := config.New()
+	if err != nil {
+		fmt.Fprintf(tw, "config\tFAIL\t%v\n", err)
+		return err
+	}
+	fmt.Fprintf(tw, "config\tOK\tendpoint %s\n", conf.OpenAIEndpoint)
+
+	key := redact.Key(conf.OpenAIKey)
+	if err := checkUpstream(conf); err != nil {
+		fmt.Fprintf(tw, "key %s\tFAIL\t%s\n", key, redact.String(err.Error(), conf.Secrets()...))
+		return err
+	}
+	fmt.Fprintf(tw, "key %s\tOK\t%s reachable\n", key, modelsPath)
+	return nil
+}
+
+// checkUpstream lists models through the same transport the proxy uses, so
+// request signing and redirect handling are checked too.
+func checkUpstream(conf *config.Config) error {
+	upstream, err := url.Parse(conf.OpenAIEndpoint)
+	if err != nil {
+		return err
+	}
+	rt, err := newRoundTripper(conf, upstream)
+	if err != nil {
+		return err
+	}
+	if t, ok := rt.(interface{ CloseIdleConnections() }); ok {
+		defer t.CloseIdleConnections()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, conf.OpenAIEndpoint+modelsPath, nil)
+	if err != nil {
+		return err
+	}
+	setAuth(req, conf.AuthStyle, conf.OpenAIKey)
+	req.Header.Set("User-Agent", conf.UserAgent)
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("upstream returned %s", resp.Status)
+	}
+	return nil
+}
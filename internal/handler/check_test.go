@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+func TestCheckUpstreamSigns(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get(authHeader), "AWS4-HMAC-SHA256 ") {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer upstream.Close()
+
+	conf := &config.Config{
+		OpenAIEndpoint:     upstream.URL,
+		RequestSigner:      config.SignerSigV4,
+		AWSAccessKeyID:     "AKIDEXAMPLE",
+		AWSSecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		AWSRegion:          "us-east-1",
+		AWSService:         "bedrock",
+	}
+	if err := checkUpstream(conf); err != nil {
+		t.Fatal(err)
+	}
+}
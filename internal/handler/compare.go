@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxCompareModels = 10
+
+type compareRequest struct {
+	Prompt string   `json:"prompt" binding:"required"`
+	Models []string `json:"models" binding:"required,min=1"`
+}
+
+// Compare sends the same prompt to several models concurrently and returns
+// their responses side by side. It is an evaluation tool, not for the hot
+// path.
+func Compare(c *gin.Context) {
+	var cr compareRequest
+	if err := c.ShouldBindJSON(&cr); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(cr.Models) > maxCompareModels {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many models"})
+		return
+	}
+
+	results := make([]completionResult, len(cr.Models))
+	var wg sync.WaitGroup
+	for i, model := range cr.Models {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runCompletion(c.Request.Context(), model, cr.Prompt, 0, true)
+		}()
+	}
+	wg.Wait()
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
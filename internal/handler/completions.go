@@ -0,0 +1,297 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+// completionHandlers returns the middlewares run before proxying a request
+// that names a model. Chat-specific checks only run when chat is set.
+func completionHandlers(conf *config.Config, chat bool) []gin.HandlerFunc {
+	hs := []gin.HandlerFunc{rejectInMaintenance}
+	if conf.EnforceJSON {
+		hs = append(hs, requireJSON)
+	}
+	if conf.ModelAliasTarget != "" {
+		hs = append(hs, resolveModelAlias)
+	}
+	if len(conf.DisabledModels) > 0 {
+		hs = append(hs, rejectDisabledModel)
+	}
+	if defaults, _ := conf.DefaultParams(); chat && len(defaults) > 0 {
+		hs = append(hs, applyDefaultParams(defaults))
+	}
+	if prompts, _ := conf.SystemPrompts(); chat && len(prompts) > 0 {
+		hs = append(hs, injectSystemPrompt(prompts))
+	}
+	if caps, _ := conf.MaxTokens(); chat && len(caps) > 0 {
+		hs = append(hs, clampMaxTokens(caps))
+	}
+	if chat && conf.ValidateCompletions {
+		hs = append(hs, validateCompletion)
+	}
+	if chat && respCache != nil {
+		hs = append(hs, respCache.serve)
+	}
+	if streams != nil {
+		hs = append(hs, streams.limit)
+	}
+	if budget != nil {
+		hs = append(hs, budget.enforce)
+	}
+	return hs
+}
+
+// modelRoutes are the routes, besides chat completions, whose requests name
+// a model and so go through completionHandlers.
+var modelRoutes = []string{
+	"/v1/responses",
+	"/v1/completions",
+	"/v1/embeddings",
+	"/v1/moderations",
+	"/v1/audio/*path",
+	"/v1/images/*path",
+}
+
+// isUploadRoute reports whether path takes multipart uploads, such as audio
+// transcriptions and image edits.
+func isUploadRoute(path string) bool {
+	return strings.HasPrefix(path, "/v1/audio/") || strings.HasPrefix(path, "/v1/images/")
+}
+
+// requireJSON rejects model requests that aren't sent as JSON, or as a
+// multipart upload where the route takes one, and asks the upstream for the
+// matching response type.
+func requireJSON(c *gin.Context) {
+	if c.Request.Method != http.MethodPost {
+		return
+	}
+	mt, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mt != "application/json" && !(mt == "multipart/form-data" && isUploadRoute(c.Request.URL.Path)) {
+		writeError(c.Writer, http.StatusUnsupportedMediaType, "invalid_request_error",
+			"Content-Type must be application/json")
+		c.Abort()
+		return
+	}
+	if c.GetHeader("Accept") != "" {
+		return
+	}
+	body, err := jsonBody(c)
+	if err != nil {
+		return
+	}
+	if isStreaming(body) {
+		c.Request.Header.Set("Accept", "text/event-stream")
+	} else {
+		c.Request.Header.Set("Accept", "application/json")
+	}
+}
+
+// resolveModelAlias rewrites the configured alias (e.g. "auto") to the
+// model it stands for before the request is routed.
+func resolveModelAlias(c *gin.Context) {
+	body, err := jsonBody(c)
+	if err != nil || body == nil || requestModel(body) != proxyConf.ModelAlias {
+		return
+	}
+	var req map[string]json.RawMessage
+	if err := json.Unmarshal(body, &req); err != nil {
+		return
+	}
+	req["model"], _ = json.Marshal(proxyConf.ModelAliasTarget)
+	body, err = json.Marshal(req)
+	if err != nil {
+		return
+	}
+	setJSONBody(c, body)
+}
+
+// rejectDisabledModel refuses requests for models an administrator has
+// disabled, and bodies it can't find the model in.
+func rejectDisabledModel(c *gin.Context) {
+	model, err := bodyModel(c)
+	if err != nil {
+		if c.IsAborted() {
+			return
+		}
+		writeError(c.Writer, http.StatusBadRequest, "invalid_request_error", err.Error())
+		c.Abort()
+		return
+	}
+	if model != "" && proxyConf.ModelDisabled(model) {
+		writeError(c.Writer, http.StatusForbidden, "invalid_request_error", proxyConf.DisabledModelMessage)
+		c.Abort()
+	}
+}
+
+// applyDefaultParams fills in params a chat completion leaves out from the
+// defaults for its model. Values the client sent always win.
+func applyDefaultParams(defaults config.DefaultParams) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := jsonBody(c)
+		if err != nil || body == nil {
+			return
+		}
+		var req map[string]json.RawMessage
+		if err := json.Unmarshal(body, &req); err != nil {
+			return
+		}
+		added := false
+		for k, v := range defaults.For(requestModel(body)) {
+			if _, ok := req[k]; !ok {
+				req[k] = v
+				added = true
+			}
+		}
+		if !added {
+			return
+		}
+		if body, err = json.Marshal(req); err != nil {
+			return
+		}
+		setJSONBody(c, body)
+	}
+}
+
+// injectSystemPrompt adds the system prompt for a chat completion's model.
+// It is merged into the first system message, or sent as a new first
+// message, and skipped when the client already sent it.
+func injectSystemPrompt(prompts config.SystemPrompts) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := jsonBody(c)
+		if err != nil || body == nil {
+			return
+		}
+		prompt := prompts.For(requestModel(body))
+		if prompt == "" {
+			return
+		}
+		var req map[string]json.RawMessage
+		if err := json.Unmarshal(body, &req); err != nil {
+			return
+		}
+		var messages []map[string]any
+		if err := json.Unmarshal(req["messages"], &messages); err != nil {
+			return
+		}
+		for _, m := range messages {
+			if m["role"] == "system" && m["content"] == prompt {
+				return
+			}
+		}
+		var content string
+		merge := len(messages) > 0 && messages[0]["role"] == "system"
+		if merge {
+			content, merge = messages[0]["content"].(string)
+		}
+		if merge {
+			messages[0]["content"] = prompt + "\n\n" + content
+		} else {
+			messages = append([]map[string]any{{"role": "system", "content": prompt}}, messages...)
+		}
+		if req["messages"], err = json.Marshal(messages); err != nil {
+			return
+		}
+		if body, err = json.Marshal(req); err != nil {
+			return
+		}
+		setJSONBody(c, body)
+	}
+}
+
+// clampMaxTokens lowers the token limits a chat completion asks for to the
+// cap for its model. Requests under the cap are left untouched.
+func clampMaxTokens(caps config.MaxTokens) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := jsonBody(c)
+		if err != nil || body == nil {
+			return
+		}
+		limit := caps.For(requestModel(body))
+		if limit == 0 {
+			return
+		}
+		var req map[string]json.RawMessage
+		if err := json.Unmarshal(body, &req); err != nil {
+			return
+		}
+		clamped := false
+		for _, key := range []string{"max_tokens", "max_completion_tokens"} {
+			var n float64
+			if json.Unmarshal(req[key], &n) != nil || n <= float64(limit) {
+				continue
+			}
+			req[key], _ = json.Marshal(limit)
+			clamped = true
+			slog.Info("max tokens clamped", "param", key, "requested", n, "cap", limit)
+		}
+		if !clamped {
+			return
+		}
+		if body, err = json.Marshal(req); err != nil {
+			return
+		}
+		setJSONBody(c, body)
+	}
+}
+
+// validateCompletion rejects chat completion bodies that the upstream would
+// refuse anyway, saving the round trip.
+func validateCompletion(c *gin.Context) {
+	body, err := jsonBody(c)
+	if err != nil {
+		if c.IsAborted() {
+			return
+		}
+		writeError(c.Writer, http.StatusBadRequest, "invalid_request_error", err.Error())
+		c.Abort()
+		return
+	}
+	if body == nil {
+		return
+	}
+	if err := checkChatCompletion(body); err != nil {
+		writeError(c.Writer, http.StatusBadRequest, "invalid_request_error", err.Error())
+		c.Abort()
+	}
+}
+
+func checkChatCompletion(body []byte) error {
+	var req struct {
+		Model    any               `json:"model"`
+		Messages []json.RawMessage `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	if m, ok := req.Model.(string); !ok || m == "" {
+		return fmt.Errorf("'model' must be a non-empty string")
+	}
+	if len(req.Messages) == 0 {
+		return fmt.Errorf("'messages' must be a non-empty array")
+	}
+	for i, raw := range req.Messages {
+		var msg map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &msg); err != nil || msg == nil {
+			return fmt.Errorf("'messages[%d]' must be an object", i)
+		}
+		var role string
+		if err := json.Unmarshal(msg["role"], &role); err != nil || role == "" {
+			return fmt.Errorf("'messages[%d].role' must be a non-empty string", i)
+		}
+		_, hasContent := msg["content"]
+		_, hasToolCalls := msg["tool_calls"]
+		_, hasFunctionCall := msg["function_call"]
+		if !hasContent && !hasToolCalls && !hasFunctionCall {
+			return fmt.Errorf("'messages[%d].content' is required", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+func TestInjectSystemPrompt(t *testing.T) {
+	defer func(conf *config.Config) { proxyConf = conf }(proxyConf)
+	proxyConf = &config.Config{}
+	prompts := config.SystemPrompts{"gpt-4o*": "Be brief."}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "prepended",
+			body: `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`,
+			want: `{"messages":[{"content":"Be brief.","role":"system"},{"content":"hi","role":"user"}],"model":"gpt-4o"}`,
+		},
+		{
+			name: "merged into the first system message",
+			body: `{"model":"gpt-4o","messages":[{"role":"system","content":"Speak French."},{"role":"user","content":"hi"}]}`,
+			want: `{"messages":[{"content":"Be brief.\n\nSpeak French.","role":"system"},{"content":"hi","role":"user"}],"model":"gpt-4o"}`,
+		},
+		{
+			name: "identical system message",
+			body: `{"model":"gpt-4o","messages":[{"role":"system","content":"Be brief."}]}`,
+			want: `{"model":"gpt-4o","messages":[{"role":"system","content":"Be brief."}]}`,
+		},
+		{
+			name: "other model",
+			body: `{"model":"o3","messages":[]}`,
+			want: `{"model":"o3","messages":[]}`,
+		},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			r := gin.New()
+			r.POST("/v1/chat/completions", injectSystemPrompt(prompts), func(c *gin.Context) {
+				body, _ := jsonBody(c)
+				got = string(body)
+			})
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
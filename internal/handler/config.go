@@ -0,0 +1,12 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Config returns the effective config with secrets masked.
+func Config(c *gin.Context) {
+	c.JSON(http.StatusOK, proxyConf.Masked())
+}
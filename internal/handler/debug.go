@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+	"runtime"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orvice/openapi-proxy/internal/version"
+)
+
+// DebugState dumps the proxy's runtime state. Secrets are masked.
+func DebugState(c *gin.Context) {
+	state := gin.H{
+		"version":     version.Version,
+		"commit":      version.Commit,
+		"goroutines":  runtime.NumGoroutine(),
+		"log_level":   logLevel.Level().String(),
+		"maintenance": maintenance.Load(),
+		"config":      proxyConf.Masked(),
+	}
+	if respCache != nil {
+		state["response_cache"] = respCache.stats()
+	}
+	if streams != nil {
+		state["streams"] = streams.stats()
+	}
+	if budget != nil {
+		state["budget"] = budget.stats()
+	}
+	c.JSON(http.StatusOK, state)
+}
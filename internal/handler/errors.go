@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/orvice/openapi-proxy/internal/config"
+	"github.com/orvice/openapi-proxy/internal/redact"
+)
+
+// writeError writes an OpenAI-shaped error response.
+func writeError(w http.ResponseWriter, status int, typ, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"message": message,
+			"type":    typ,
+			"code":    status,
+		},
+	})
+}
+
+// setRetryAfter suggests a randomized backoff on 502/503 responses that
+// don't already carry one.
+func setRetryAfter(h http.Header, status int, max time.Duration) {
+	if max <= 0 || h.Get("Retry-After") != "" ||
+		(status != http.StatusBadGateway && status != http.StatusServiceUnavailable) {
+		return
+	}
+	secs := int64(max / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	h.Set("Retry-After", strconv.FormatInt(1+rand.Int64N(secs), 10))
+}
+
+// writeTemplateError renders an error response with the operator's template.
+func writeTemplateError(w http.ResponseWriter, tmpl *template.Template, data config.ErrorData) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		slog.Error("execute error template error", "error", err)
+		writeError(w, data.Status, data.Type, data.Message)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(data.Status)
+	w.Write(buf.Bytes())
+}
+
+// upstreamErrorStatus maps a failed upstream round trip to a status code.
+func upstreamErrorStatus(err error) int {
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+// scrubKeyParam masks the key query parameter of req in err, since
+// AUTH_STYLE=api-key-query puts the key in the URL and transport errors
+// quote it.
+func scrubKeyParam(err error, req *http.Request) error {
+	key := req.URL.Query().Get(apiKeyQueryParam)
+	if key == "" || !strings.Contains(err.Error(), key) {
+		return err
+	}
+	return errors.New(strings.ReplaceAll(err.Error(), key, redact.Key(key)))
+}
+
+// maxErrorBody bounds how much of an upstream error body is buffered.
+const maxErrorBody = 1 << 20
+
+// redactErrorBody scrubs keys out of upstream error responses before they
+// reach the client, since some upstreams echo the credentials they rejected.
+// modifyRequest drops Accept-Encoding, so the body arrives decoded.
+func redactErrorBody(resp *http.Response, conf *config.Config) error {
+	if resp.StatusCode < http.StatusBadRequest ||
+		strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	body = []byte(redact.String(string(body), conf.Secrets()...))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	resp.ContentLength = int64(len(body))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
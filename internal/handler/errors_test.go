@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+func TestRedactErrorBody(t *testing.T) {
+	conf := &config.Config{OpenAIKey: "upstream-secret-key"}
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			name:        "error with echoed key",
+			status:      http.StatusUnauthorized,
+			contentType: "application/json",
+			body:        `{"error":{"message":"Incorrect API key provided: sk-proj-abcdefghijklmnop"}}`,
+			want:        `{"error":{"message":"Incorrect API key provided: sk-...mnop"}}`,
+		},
+		{
+			name:        "error with configured key",
+			status:      http.StatusForbidden,
+			contentType: "text/plain",
+			body:        "key upstream-secret-key is revoked",
+			want:        "key ups...-key is revoked",
+		},
+		{
+			name:        "success is untouched",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"id":"sk-proj-abcdefghijklmnop"}`,
+			want:        `{"id":"sk-proj-abcdefghijklmnop"}`,
+		},
+		{
+			name:        "event stream is untouched",
+			status:      http.StatusBadRequest,
+			contentType: "text/event-stream",
+			body:        "data: sk-proj-abcdefghijklmnop\n\n",
+			want:        "data: sk-proj-abcdefghijklmnop\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: tt.status,
+				Header:     http.Header{"Content-Type": {tt.contentType}},
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+			if err := redactErrorBody(resp, conf); err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+			if tt.status >= http.StatusBadRequest && tt.contentType != "text/event-stream" {
+				if cl := resp.Header.Get("Content-Length"); cl != strconv.Itoa(len(tt.want)) {
+					t.Errorf("Content-Length = %s, want %d", cl, len(tt.want))
+				}
+			}
+		})
+	}
+}
+
+func TestScrubKeyParam(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://api.example.com/v1/chat/completions?key=client-secret-key&alt=json", nil)
+	err := fmt.Errorf("upstream: %w", &url.Error{Op: "Post", URL: req.URL.String(), Err: io.ErrUnexpectedEOF})
+
+	got := scrubKeyParam(err, req).Error()
+	want := `upstream: Post "https://api.example.com/v1/chat/completions?key=cli...-key&alt=json": unexpected EOF`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
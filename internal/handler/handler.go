@@ -1,60 +1,95 @@
 package handler
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
-	"strings"
+	"os"
+	"text/template"
 
 	"github.com/gin-gonic/gin"
 	"github.com/orvice/openapi-proxy/internal/config"
+	"github.com/orvice/openapi-proxy/internal/redact"
 )
 
 var (
 	authHeader = "Authorization"
 
+	router      *gin.Engine
 	openAIProxy *httputil.ReverseProxy
+	proxyConf   *config.Config
+	respCache   *responseCache
+	streams     *streamLimiter
+	budget      *requestBudget
 )
 
 // NewProxy takes target host and creates a reverse proxy
+// newRoundTripper returns the transport upstream requests go out on, with
+// request signing and redirect following applied as configured.
+func newRoundTripper(conf *config.Config, upstream *url.URL) (http.RoundTripper, error) {
+	transport, err := newTransport(conf)
+	if err != nil {
+		return nil, err
+	}
+	var rt http.RoundTripper = transport
+	if sign := newSigner(conf); sign != nil {
+		rt = &signingTransport{base: transport, signer: sign}
+	}
+	if conf.RedirectMode == config.RedirectFollow {
+		rt = newRedirectFollower(conf, upstream, rt)
+	}
+	return rt, nil
+}
+
 func NewProxy(conf *config.Config) (*httputil.ReverseProxy, error) {
 	url, err := url.Parse(conf.OpenAIEndpoint)
 	if err != nil {
 		return nil, err
 	}
 
+	rt, err := newRoundTripper(conf, url)
+	if err != nil {
+		return nil, err
+	}
 	proxy := httputil.NewSingleHostReverseProxy(url)
+	proxy.Transport = rt
 
 	originalDirector := proxy.Director
 	proxy.Director = func(req *http.Request) {
 		originalDirector(req)
 		modifyRequest(req, conf)
+		if conf.TraceTimings {
+			withTimings(req)
+		}
+		if conf.UpstreamLatencyHeader {
+			markDispatch(req)
+		}
+		if conf.Tracing {
+			injectTraceContext(req)
+		}
 	}
 
-	proxy.ModifyResponse = modifyResponse()
-	proxy.ErrorHandler = errorHandler()
+	headerRules, err := conf.ResponseHeaderRules()
+	if err != nil {
+		return nil, err
+	}
+	proxy.ModifyResponse = modifyResponse(conf, url, headerRules)
+	errTmpl, err := config.ErrorTemplate(conf.ErrorTemplate)
+	if err != nil {
+		return nil, err
+	}
+	proxy.ErrorHandler = errorHandler(conf, url, errTmpl)
 	return proxy, nil
 }
 
 func modifyRequest(req *http.Request, conf *config.Config) {
-	//  chekc if auth header is empty
-	if req.Header.Get(authHeader) == "" {
-		slog.Info("no token found, using default token")
-		req.Header.Set(authHeader, "Bearer "+conf.OpenAIKey)
+	req.Header.Del(adminTokenHeader)
+	if passthroughAuth(req) {
+		slog.Info("non-bearer authorization, passing through")
 	} else {
-		slog.Info("token found in request")
-		bearerHeader := req.Header.Get(authHeader)
-		arr := strings.Split(bearerHeader, " ")
-		var key string
-		if len(arr) == 2 {
-			key = arr[1]
-		}
-		if key == "null" || strings.Contains(key, "null") {
-			slog.Info(" token is null, using default token")
-			req.Header.Del(authHeader)
-			req.Header.Set(authHeader, "Bearer "+conf.OpenAIKey)
-		}
+		setAuth(req, conf.AuthStyle, requestKey(req, conf))
 	}
 	newUrl, err := url.Parse(conf.OpenAIEndpoint)
 	if err != nil {
@@ -64,34 +99,114 @@ func modifyRequest(req *http.Request, conf *config.Config) {
 	req.Host = newUrl.Host
 	req.URL.Host = newUrl.Host
 	req.Header.Set("Host", newUrl.Host)
+	req.Header.Set("User-Agent", conf.UserAgent)
+	// Let the transport negotiate compression and decode it, so model
+	// listings can be rewritten and error bodies redacted.
+	req.Header.Del("Accept-Encoding")
 }
 
-func errorHandler() func(http.ResponseWriter, *http.Request, error) {
+func errorHandler(conf *config.Config, upstream *url.URL, errTmpl *template.Template) func(http.ResponseWriter, *http.Request, error) {
 	return func(w http.ResponseWriter, req *http.Request, err error) {
-		slog.Error("Got error while modifying response", "error", err)
+		status := upstreamErrorStatus(err)
+		err = scrubKeyParam(err, req)
+		msg := redact.String(err.Error(), conf.Secrets()...)
+		slog.Error("Got error while modifying response", "error", msg)
+		logTimings(req, status)
+		if conf.Tracing {
+			endSpanError(req, status, err)
+		}
+		setRetryAfter(w.Header(), status, conf.RetryAfterMax)
+		if errTmpl != nil {
+			writeTemplateError(w, errTmpl, config.ErrorData{
+				Message:  msg,
+				Type:     "proxy_error",
+				Status:   status,
+				Upstream: upstream.Host,
+			})
+			return
+		}
+		writeError(w, status, "proxy_error", msg)
 	}
 }
 
-func modifyResponse() func(*http.Response) error {
+func modifyResponse(conf *config.Config, upstream *url.URL, headerRules config.HeaderRules) func(*http.Response) error {
 	return func(resp *http.Response) error {
-		return nil
+		logTimings(resp.Request, resp.StatusCode)
+		if conf.UpstreamLatencyHeader {
+			setUpstreamLatency(resp)
+		}
+		if conf.Tracing {
+			endSpanResponse(resp)
+		}
+		rewriteHeaders(resp.Header, headerRules)
+		setRetryAfter(resp.Header, resp.StatusCode, conf.RetryAfterMax)
+		if model := resolvedModel(resp.Request); model != "" {
+			resp.Header.Set(resolvedModelHeader, model)
+		}
+		if conf.RedirectMode == config.RedirectRewrite {
+			rewriteRedirect(resp, upstream)
+		}
+		if err := redactErrorBody(resp, conf); err != nil {
+			return err
+		}
+		if respCache != nil {
+			if err := respCache.storeResponse(resp); err != nil {
+				return err
+			}
+		}
+		if conf.StreamErrorFrame {
+			guardStream(resp)
+		}
+		if err := rewriteModels(resp, conf); err != nil {
+			return err
+		}
+		return setModelsETag(resp)
 	}
 }
 
-func Router(r *gin.Engine) {
-	conf, err := config.New()
+// exitOnError stops startup when err is set. Router can't return an error
+// to the framework, and a half-configured proxy shouldn't serve traffic.
+func exitOnError(msg string, err error) {
 	if err != nil {
-		slog.Error("new config error", "error", err)
-		return
+		slog.Error(msg, "error", err)
+		os.Exit(1)
 	}
+}
 
+func Router(r *gin.Engine) {
+	conf, err := config.New()
+	exitOnError("new config error", err)
+
+	// The framework's logger is left alone unless its level can change.
+	if conf.LogLevel != "" || conf.AdminToken != "" {
+		setupLogLevel(conf.LogLevel)
+	}
 	slog.Info("new config", slog.Any("config", conf))
+	router = r
+	proxyConf = conf
+	maintenance.Store(conf.Maintenance)
+	if conf.ResponseCacheTTL > 0 {
+		respCache = newResponseCache(conf.ResponseCacheTTL, conf.ResponseCacheMaxEntries)
+	}
+	if conf.MaxStreamsPerClient > 0 {
+		streams = newStreamLimiter(conf.MaxStreamsPerClient)
+	}
+	if conf.RequestBudget > 0 {
+		budget = newRequestBudget(conf.RequestBudget, conf.RequestBudgetWindow)
+	}
 	openAIProxy, err = NewProxy(conf)
-	if err != nil {
-		slog.Error("new proxy error", "error", err)
-		return
+	exitOnError("new proxy error", err)
+	exitOnError("set trusted proxies error", r.SetTrustedProxies(conf.TrustedProxies))
+	if conf.TrustCloudflare {
+		r.TrustedPlatform = gin.PlatformCloudflare
+	}
+	r.GET("/version", Version)
+	r.GET("/healthz", Healthz)
+	exitOnError("serve admin error", serveAdmin(r, conf))
+	r.Any("/v1/chat/completions", append(completionHandlers(conf, true), proxy)...)
+	for _, path := range modelRoutes {
+		r.Any(path, append(completionHandlers(conf, false), proxy)...)
 	}
-	r.Any("/v1/chat/completions", proxy)
 	r.NoRoute(proxy)
 }
 
@@ -101,7 +216,49 @@ func proxy(c *gin.Context) {
 		"ua", c.Request.UserAgent(),
 		"method", c.Request.Method,
 		"path", c.Request.URL.Path)
-	openAIProxy.ServeHTTP(c.Writer, c.Request)
+	if proxyConf.Tracing {
+		span := startSpan(c)
+		defer span.End()
+	}
+
+	upstream := openAIProxy
+	override, status, err := overrideProxy(c)
+	if err != nil {
+		writeError(c.Writer, status, "invalid_request_error", err.Error())
+		return
+	}
+	if override != nil {
+		upstream = override
+		if t, ok := override.Transport.(interface{ CloseIdleConnections() }); ok {
+			defer t.CloseIdleConnections()
+		}
+	}
+
+	// Bodies are only read when a feature needs them: the middlewares, or
+	// the whole-call timeout telling streams apart on model routes. Anything
+	// else, such as NoRoute (which has no FullPath), streams through.
+	if proxyConf.UpstreamTimeout > 0 && c.FullPath() != "" {
+		if _, err := jsonBody(c); err != nil {
+			if !c.IsAborted() {
+				writeError(c.Writer, http.StatusBadRequest, "invalid_request_error", err.Error())
+			}
+			return
+		}
+	}
+	body, buffered := bufferedBody(c)
+	if buffered {
+		if model, _ := bodyModel(c); model != "" {
+			c.Request = withResolvedModel(c.Request, model)
+		}
+	}
+	// Only requests known not to stream get the whole-call deadline; the
+	// rest only get the first-byte deadline set on the transport.
+	if proxyConf.UpstreamTimeout > 0 && buffered && !isMultipart(c) && !isStreaming(body) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), proxyConf.UpstreamTimeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+	}
+	upstream.ServeHTTP(c.Writer, c.Request)
 }
 
 func ChatComplections(c *gin.Context) {
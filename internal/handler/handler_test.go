@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+func TestProxyUpstreamTimeout(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("done"))
+	}))
+	defer upstream.Close()
+
+	defer func(p *httputil.ReverseProxy, conf *config.Config) {
+		openAIProxy, proxyConf = p, conf
+	}(openAIProxy, proxyConf)
+	proxyConf = &config.Config{OpenAIEndpoint: upstream.URL, UpstreamTimeout: 100 * time.Millisecond}
+	var err error
+	if openAIProxy, err = NewProxy(proxyConf); err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/v1/embeddings", proxy)
+	r.NoRoute(proxy)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		wantDone bool
+	}{
+		{"stream on a model route", "/v1/embeddings", `{"stream":true}`, true},
+		{"stream on NoRoute", "/v1/threads/t/runs", `{"stream":true}`, true},
+		{"non-stream on a model route", "/v1/embeddings", `{}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(srv.URL+tt.path, "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if done := strings.Contains(string(body), "done"); done != tt.wantDone {
+				t.Errorf("body %q, want complete = %v", body, tt.wantDone)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+// rewriteHeaders applies rules to h.
+func rewriteHeaders(h http.Header, rules config.HeaderRules) {
+	for _, name := range rules.Remove {
+		h.Del(name)
+	}
+	for name, values := range rules.Set {
+		h[name] = append([]string(nil), values...)
+	}
+	for name, values := range rules.Add {
+		for _, v := range values {
+			h.Add(name, v)
+		}
+	}
+}
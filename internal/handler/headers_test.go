@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+func TestRewriteHeaders(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    http.Header
+		rules config.HeaderRules
+		want  http.Header
+	}{
+		{
+			name:  "remove",
+			in:    http.Header{"Openai-Organization": {"org-1"}, "Content-Type": {"application/json"}},
+			rules: config.HeaderRules{Remove: []string{"openai-organization"}},
+			want:  http.Header{"Content-Type": {"application/json"}},
+		},
+		{
+			name:  "set overrides upstream values",
+			in:    http.Header{"Cache-Control": {"no-cache", "private"}},
+			rules: config.HeaderRules{Set: http.Header{"Cache-Control": {"max-age=60; public"}}},
+			want:  http.Header{"Cache-Control": {"max-age=60; public"}},
+		},
+		{
+			name:  "add appends to upstream values",
+			in:    http.Header{"Via": {"1.1 upstream"}},
+			rules: config.HeaderRules{Add: http.Header{"Via": {"1.1 proxy"}, "X-Served-By": {"proxy"}}},
+			want:  http.Header{"Via": {"1.1 upstream", "1.1 proxy"}, "X-Served-By": {"proxy"}},
+		},
+		{
+			name: "remove runs before set and add",
+			in:   http.Header{"X-Test": {"upstream"}},
+			rules: config.HeaderRules{
+				Remove: []string{"X-Test"},
+				Set:    http.Header{"X-Test": {"set"}},
+				Add:    http.Header{"X-Test": {"added"}},
+			},
+			want: http.Header{"X-Test": {"set", "added"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rewriteHeaders(tt.in, tt.rules)
+			if !reflect.DeepEqual(tt.in, tt.want) {
+				t.Errorf("headers = %v, want %v", tt.in, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+var logLevel = new(slog.LevelVar)
+
+// stdLogger is the stdlib's default logger, captured before the framework
+// replaces it. Its handler writes through the log package, which
+// slog.SetDefault redirects back to slog, so wrapping it would loop.
+var stdLogger = slog.Default()
+
+// levelHandler gates records on logLevel and hands them to the wrapped
+// handler, bypassing that handler's own level so debug can be turned on.
+type levelHandler struct {
+	inner slog.Handler
+}
+
+func (h levelHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= logLevel.Level()
+}
+
+func (h levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.inner.Handle(ctx, r)
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{inner: h.inner.WithAttrs(attrs)}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{inner: h.inner.WithGroup(name)}
+}
+
+// setupLogLevel makes the default logger honour logLevel, starting at level
+// or, when level is empty, at the level the current logger already logs at.
+func setupLogLevel(level string) {
+	inner := slog.Default().Handler()
+	if slog.Default() == stdLogger {
+		inner = slog.NewTextHandler(os.Stderr, nil)
+	}
+	var l slog.Level
+	if level == "" || l.UnmarshalText([]byte(level)) != nil {
+		l = lowestLevel(inner)
+	}
+	logLevel.Set(l)
+	slog.SetDefault(slog.New(levelHandler{inner: inner}))
+}
+
+// lowestLevel returns the lowest standard level h logs at.
+func lowestLevel(h slog.Handler) slog.Level {
+	for _, l := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn} {
+		if h.Enabled(context.Background(), l) {
+			return l
+		}
+	}
+	return slog.LevelError
+}
+
+func GetLogLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"level": logLevel.Level().String()})
+}
+
+// SetLogLevel changes the log level at runtime, e.g. ?level=debug.
+func SetLogLevel(c *gin.Context) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(c.Query("level"))); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	logLevel.Set(l)
+	slog.Info("log level changed", "level", l.String())
+	c.JSON(http.StatusOK, gin.H{"level": l.String()})
+}
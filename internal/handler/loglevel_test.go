@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSetupLogLevelWrapsDefault(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	defer logLevel.Set(logLevel.Level())
+
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	setupLogLevel("")
+	if got := logLevel.Level(); got != slog.LevelWarn {
+		t.Errorf("initial level = %v, want the framework's %v", got, slog.LevelWarn)
+	}
+
+	slog.Info("hidden")
+	logLevel.Set(slog.LevelDebug)
+	slog.Debug("shown")
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Errorf("info record logged at warn level: %s", out)
+	}
+	if !strings.Contains(out, `"msg":"shown"`) {
+		t.Errorf("debug record missing or not in the framework's format: %q", out)
+	}
+}
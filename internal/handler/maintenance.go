@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+var maintenance atomic.Bool
+
+// rejectInMaintenance turns completion traffic away while maintenance mode
+// is on.
+func rejectInMaintenance(c *gin.Context) {
+	if !maintenance.Load() {
+		return
+	}
+	c.Header("Retry-After", "60")
+	writeError(c.Writer, http.StatusServiceUnavailable, "service_unavailable", "service in maintenance")
+	c.Abort()
+}
+
+func GetMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"enabled": maintenance.Load()})
+}
+
+// SetMaintenance toggles maintenance mode, e.g. ?enabled=true.
+func SetMaintenance(c *gin.Context) {
+	enabled, err := strconv.ParseBool(c.Query("enabled"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	maintenance.Store(enabled)
+	slog.Info("maintenance mode changed", "enabled", enabled)
+	c.JSON(http.StatusOK, gin.H{"enabled": enabled})
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics are registered with the default registry, which the service's
+// /metrics endpoint exposes.
+var (
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "openai_proxy_response_cache_requests_total",
+		Help: "Cacheable requests by result, hit or miss.",
+	}, []string{"result"})
+	cacheEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "openai_proxy_response_cache_entries",
+		Help: "Entries currently held in the response cache.",
+	})
+	cacheEvictions = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "openai_proxy_response_cache_evictions_total",
+		Help: "Response cache entries dropped for expiry or size.",
+	})
+
+	activeStreams = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "openai_proxy_active_streams",
+		Help: "Streaming requests currently held open, across all clients.",
+	})
+	streamsRejected = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "openai_proxy_streams_rejected_total",
+		Help: "Streaming requests refused by MAX_STREAMS_PER_CLIENT.",
+	})
+)
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+const modelsPath = "/v1/models"
+
+// setModelsETag tags a successful /v1/models listing with an ETag derived
+// from its body and turns it into a 304 when the client already has it.
+func setModelsETag(resp *http.Response) error {
+	req := resp.Request
+	if req == nil || req.Method != http.MethodGet || req.URL.Path != modelsPath ||
+		resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	resp.Header.Set("ETag", etag)
+
+	if req.Header.Get("If-None-Match") == etag {
+		resp.StatusCode = http.StatusNotModified
+		resp.Status = "304 Not Modified"
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = 0
+		resp.Body = http.NoBody
+		return nil
+	}
+
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	resp.ContentLength = int64(len(body))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+// rewriteModels drops disabled models from a /v1/models listing and
+// advertises the model alias.
+func rewriteModels(resp *http.Response, conf *config.Config) error {
+	req := resp.Request
+	if (len(conf.DisabledModels) == 0 && conf.ModelAliasTarget == "") || req == nil || req.Method != http.MethodGet ||
+		req.URL.Path != modelsPath || resp.StatusCode != http.StatusOK ||
+		resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	var list map[string]json.RawMessage
+	var models []map[string]any
+	if json.Unmarshal(body, &list) == nil && json.Unmarshal(list["data"], &models) == nil {
+		kept := models[:0]
+		for _, m := range models {
+			if id, _ := m["id"].(string); !conf.ModelDisabled(id) {
+				kept = append(kept, m)
+			}
+		}
+		if conf.ModelAliasTarget != "" && !conf.ModelDisabled(conf.ModelAliasTarget) {
+			kept = append(kept, map[string]any{
+				"id":       conf.ModelAlias,
+				"object":   "model",
+				"created":  0,
+				"owned_by": conf.ModelAliasOwnedBy,
+			})
+		}
+		if data, err := json.Marshal(kept); err == nil {
+			list["data"] = data
+			if b, err := json.Marshal(list); err == nil {
+				body = b
+			}
+		}
+	}
+
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	resp.ContentLength = int64(len(body))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+func TestRewriteModels(t *testing.T) {
+	const upstream = `{"object":"list","data":[{"id":"gpt-4o","owned_by":"openai"},{"id":"meta/llama-3","owned_by":"meta"}]}`
+	tests := []struct {
+		name string
+		conf config.Config
+		want string
+	}{
+		{
+			name: "alias with its owner",
+			conf: config.Config{ModelAlias: "auto", ModelAliasTarget: "gpt-4o", ModelAliasOwnedBy: "acme"},
+			want: `{"data":[{"id":"gpt-4o","owned_by":"openai"},{"id":"meta/llama-3","owned_by":"meta"},{"created":0,"id":"auto","object":"model","owned_by":"acme"}],"object":"list"}`,
+		},
+		{
+			name: "disabled models and an alias to one",
+			conf: config.Config{ModelAlias: "auto", ModelAliasTarget: "meta/llama-3", DisabledModels: []string{"*llama*"}},
+			want: `{"data":[{"id":"gpt-4o","owned_by":"openai"}],"object":"list"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(upstream)),
+				Request:    httptest.NewRequest(http.MethodGet, modelsPath, nil),
+			}
+			if err := rewriteModels(resp, &tt.conf); err != nil {
+				t.Fatal(err)
+			}
+			got, _ := io.ReadAll(resp.Body)
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
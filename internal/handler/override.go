@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+// upstreamHostHeader points a single request at another upstream. Only
+// honoured together with a valid admin token.
+const upstreamHostHeader = "X-Upstream-Host"
+
+// overrideProxy returns a one-off proxy for a request carrying
+// X-Upstream-Host, or nil when the request should use the default proxy.
+func overrideProxy(c *gin.Context) (*httputil.ReverseProxy, int, error) {
+	host := c.GetHeader(upstreamHostHeader)
+	if host == "" {
+		return nil, 0, nil
+	}
+	if !hasAdminToken(c, proxyConf.AdminToken) {
+		return nil, http.StatusForbidden, fmt.Errorf("%s requires a valid %s", upstreamHostHeader, adminTokenHeader)
+	}
+	c.Request.Header.Del(upstreamHostHeader)
+
+	u, err := url.Parse(host)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid %s %q", upstreamHostHeader, host)
+	}
+
+	// The configured key and signing credentials are only for the configured
+	// upstream; another host only gets what the client sent.
+	conf := *proxyConf
+	conf.OpenAIEndpoint = u.Scheme + "://" + u.Host
+	conf.UnixSocket = ""
+	conf.OpenAIKey = ""
+	conf.RequestSigner = config.SignerNone
+	conf.AWSAccessKeyID = ""
+	conf.AWSSecretAccessKey = ""
+	conf.AWSSessionToken = ""
+	p, err := NewProxy(&conf)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	slog.Info("upstream overridden for request", "upstream", conf.OpenAIEndpoint)
+	return p, 0, nil
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+func TestOverrideProxyCredentials(t *testing.T) {
+	var got http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer upstream.Close()
+
+	defer func(conf *config.Config) { proxyConf = conf }(proxyConf)
+	proxyConf = &config.Config{
+		OpenAIEndpoint: "https://api.openai.com",
+		OpenAIKey:      "configured-secret-key",
+		AdminToken:     "admin-token",
+	}
+
+	tests := []struct {
+		name     string
+		auth     string
+		wantAuth string
+	}{
+		{"no client key", "", ""},
+		{"client key", "Bearer sk-client", "Bearer sk-client"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+			req.Header.Set(upstreamHostHeader, upstream.URL)
+			req.Header.Set(adminTokenHeader, "admin-token")
+			if tt.auth != "" {
+				req.Header.Set(authHeader, tt.auth)
+			}
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = req
+
+			p, _, err := overrideProxy(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.ServeHTTP(httptest.NewRecorder(), c.Request)
+			if a := got.Get(authHeader); a != tt.wantAuth {
+				t.Errorf("upstream got Authorization %q, want %q", a, tt.wantAuth)
+			}
+			if a := got.Get(adminTokenHeader); a != "" {
+				t.Errorf("upstream got %s %q", adminTokenHeader, a)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const probeTimeout = 30 * time.Second
+
+type probeRequest struct {
+	Model string `json:"model" binding:"required"`
+}
+
+// completionResult is the outcome of a chat completion sent through the
+// proxy on behalf of an admin endpoint.
+type completionResult struct {
+	Model     string `json:"model"`
+	Status    int    `json:"status"`
+	Success   bool   `json:"success"`
+	LatencyMs int64  `json:"latency_ms"`
+	Body      any    `json:"body,omitempty"`
+}
+
+// runCompletion sends a single-message chat completion through the router,
+// so it runs the same middlewares (aliases, disabled models, caps, budget)
+// and key injection as client traffic.
+func runCompletion(ctx context.Context, model, prompt string, maxTokens int, withBody bool) completionResult {
+	res := completionResult{Model: model}
+	req := map[string]any{
+		"model":    model,
+		"messages": []map[string]string{{"role": "user", "content": prompt}},
+	}
+	if maxTokens > 0 {
+		req["max_tokens"] = maxTokens
+	}
+	body, _ := json.Marshal(req)
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		res.Status = http.StatusInternalServerError
+		res.Body = err.Error()
+		return res
+	}
+	r.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	router.ServeHTTP(rec, r)
+	res.LatencyMs = time.Since(start).Milliseconds()
+	res.Status = rec.Code
+	res.Success = rec.Code == http.StatusOK
+
+	if withBody || !res.Success {
+		if json.Valid(rec.Body.Bytes()) {
+			res.Body = json.RawMessage(rec.Body.Bytes())
+		} else {
+			res.Body = rec.Body.String()
+		}
+	}
+	return res
+}
+
+// Probe sends a minimal chat completion for a model through the proxy and
+// reports whether the upstream served it.
+func Probe(c *gin.Context) {
+	var pr probeRequest
+	if err := c.ShouldBindJSON(&pr); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, runCompletion(c.Request.Context(), pr.Model, "ping", 1, false))
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+func TestRunCompletionUsesRouteChain(t *testing.T) {
+	var sent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		sent = req.Model
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer upstream.Close()
+
+	defer func(e *gin.Engine, p *httputil.ReverseProxy, conf *config.Config) {
+		router, openAIProxy, proxyConf = e, p, conf
+	}(router, openAIProxy, proxyConf)
+	proxyConf = &config.Config{
+		OpenAIEndpoint:       upstream.URL,
+		ModelAlias:           "auto",
+		ModelAliasTarget:     "gpt-4o",
+		DisabledModels:       []string{"blocked"},
+		DisabledModelMessage: "disabled",
+	}
+	var err error
+	if openAIProxy, err = NewProxy(proxyConf); err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	router = gin.New()
+	router.Any("/v1/chat/completions", append(completionHandlers(proxyConf, true), proxy)...)
+
+	tests := []struct {
+		model      string
+		wantStatus int
+		wantSent   string
+	}{
+		{"auto", http.StatusOK, "gpt-4o"},
+		{"blocked", http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			sent = ""
+			res := runCompletion(context.Background(), tt.model, "ping", 1, false)
+			if res.Status != tt.wantStatus || sent != tt.wantSent {
+				t.Errorf("status %d, upstream got %q; want %d, %q", res.Status, sent, tt.wantStatus, tt.wantSent)
+			}
+		})
+	}
+}
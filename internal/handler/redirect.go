@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+// redirectFollower is a RoundTripper that follows upstream redirects itself,
+// so clients never see a Location pointing at the upstream. Credentials are
+// only re-sent to the upstream host; once MaxRedirects is reached the last
+// redirect is returned as is.
+type redirectFollower struct {
+	conf   *config.Config
+	client *http.Client
+}
+
+func newRedirectFollower(conf *config.Config, upstream *url.URL, base http.RoundTripper) *redirectFollower {
+	f := &redirectFollower{conf: conf}
+	f.client = &http.Client{
+		Transport: base,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > conf.MaxRedirects {
+				return http.ErrUseLastResponse
+			}
+			req.Header.Set("User-Agent", conf.UserAgent)
+			if req.URL.Host != upstream.Host {
+				// http.Client keeps x-api-key across hosts, drop it too.
+				req.Header.Del(authHeader)
+				req.Header.Del(apiKeyHeader)
+				return nil
+			}
+			if !passthroughAuth(via[0]) {
+				setAuth(req, conf.AuthStyle, requestKeyFrom(via[0], conf.AuthStyle))
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+func (f *redirectFollower) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.RequestURI = ""
+	if req.Body != nil && req.Body != http.NoBody {
+		// Buffer the body so 307/308 redirects can replay it.
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		out.Body = io.NopCloser(bytes.NewReader(body))
+		out.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	return f.client.Do(out)
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped transport,
+// so one-off proxies can be cleaned up.
+func (f *redirectFollower) CloseIdleConnections() {
+	f.client.CloseIdleConnections()
+}
+
+// rewriteRedirect points a redirect to the upstream back at the proxy by
+// reducing its Location to a path on the same host.
+func rewriteRedirect(resp *http.Response, upstream *url.URL) {
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return
+	}
+	loc, err := resp.Location()
+	if err != nil || loc.Host != upstream.Host {
+		return
+	}
+	q := loc.Query()
+	q.Del(apiKeyQueryParam)
+	rel := &url.URL{Path: loc.Path, RawPath: loc.RawPath, RawQuery: q.Encode(), Fragment: loc.Fragment}
+	resp.Header.Set("Location", rel.String())
+}
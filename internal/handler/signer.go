@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+// signer signs an upstream request once the director has finished
+// rewriting it. New schemes (HMAC, JWT, ...) only need an entry in signers.
+type signer interface {
+	Sign(req *http.Request) error
+}
+
+var signers = map[string]func(conf *config.Config) signer{
+	config.SignerSigV4: newSigV4Signer,
+}
+
+// newSigner returns the signer configured by conf, or nil for none.
+func newSigner(conf *config.Config) signer {
+	if f, ok := signers[conf.RequestSigner]; ok {
+		return f(conf)
+	}
+	return nil
+}
+
+// signingTransport signs each request, including redirected ones, right
+// before it is sent. A request that can't be signed fails instead of going
+// out unsigned.
+type signingTransport struct {
+	base   *http.Transport
+	signer signer
+}
+
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if err := t.signer.Sign(req); err != nil {
+		return nil, fmt.Errorf("sign request: %w", err)
+	}
+	return t.base.RoundTrip(req)
+}
+
+func (t *signingTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
+
+// readBody returns req's body and puts a fresh copy back on the request.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	return body, nil
+}
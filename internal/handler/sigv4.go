@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	amzDateFormat   = "20060102T150405Z"
+	amzDateHeader   = "X-Amz-Date"
+	amzSHA256Header = "X-Amz-Content-Sha256"
+	amzTokenHeader  = "X-Amz-Security-Token"
+)
+
+// sigV4Signer signs requests with AWS Signature Version 4.
+type sigV4Signer struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	region       string
+	service      string
+	now          func() time.Time
+}
+
+func newSigV4Signer(conf *config.Config) signer {
+	return &sigV4Signer{
+		accessKey:    conf.AWSAccessKeyID,
+		secretKey:    conf.AWSSecretAccessKey,
+		sessionToken: conf.AWSSessionToken,
+		region:       conf.AWSRegion,
+		service:      conf.AWSService,
+		now:          time.Now,
+	}
+}
+
+func (s *sigV4Signer) Sign(req *http.Request) error {
+	body, err := readBody(req)
+	if err != nil {
+		return err
+	}
+	payloadHash := sha256Hex(body)
+	now := s.now().UTC()
+
+	req.Header.Set(amzDateHeader, now.Format(amzDateFormat))
+	req.Header.Set(amzSHA256Header, payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set(amzTokenHeader, s.sessionToken)
+	}
+	req.Header.Set(authHeader, s.authorization(req, now, payloadHash))
+	return nil
+}
+
+// authorization returns the Authorization value for req, signing Host and
+// every X-Amz-* header on it.
+func (s *sigV4Signer) authorization(req *http.Request, now time.Time, payloadHash string) string {
+	amzDate := now.Format(amzDateFormat)
+	date := amzDate[:8]
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.Join(values, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, strings.TrimSpace(headers[name]))
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req),
+		canonicalQuery(req),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.region, s.service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s.service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	return fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.accessKey, scope, signedHeaders, signature)
+}
+
+// canonicalURI encodes each segment of the path as sent on the wire.
+func canonicalURI(req *http.Request) string {
+	path := req.URL.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		segments[i] = awsURIEncode(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(req *http.Request) string {
+	q := req.URL.Query()
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, awsURIEncode(k)+"="+awsURIEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsURIEncode percent-encodes everything but RFC 3986 unreserved characters.
+func awsURIEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+var doneSentinel = []byte("[DONE]")
+
+// streamGuard wraps a completion event stream and, if the upstream stops
+// before sending [DONE], emits a final SSE error event so clients can tell
+// a truncated stream from a finished one.
+type streamGuard struct {
+	body io.ReadCloser
+	tail []byte
+	done bool
+
+	pending []byte
+	eof     bool
+}
+
+func (g *streamGuard) Read(p []byte) (int, error) {
+	if len(g.pending) > 0 {
+		n := copy(p, g.pending)
+		g.pending = g.pending[n:]
+		return n, nil
+	}
+	if g.eof {
+		return 0, io.EOF
+	}
+
+	n, err := g.body.Read(p)
+	if n > 0 && !g.done {
+		buf := append(g.tail, p[:n]...)
+		g.done = bytes.Contains(buf, doneSentinel)
+		if len(buf) > len(doneSentinel) {
+			buf = buf[len(buf)-len(doneSentinel):]
+		}
+		g.tail = append(g.tail[:0], buf...)
+	}
+	if err == nil {
+		return n, nil
+	}
+
+	g.eof = true
+	if !g.done {
+		if !errors.Is(err, io.EOF) {
+			slog.Error("upstream stream dropped", "error", err)
+		} else {
+			slog.Error("upstream stream ended without [DONE]")
+		}
+		g.pending = streamErrorFrame()
+	}
+	if n > 0 || len(g.pending) > 0 {
+		return n, nil
+	}
+	return 0, io.EOF
+}
+
+func (g *streamGuard) Close() error {
+	return g.body.Close()
+}
+
+func streamErrorFrame() []byte {
+	b, _ := json.Marshal(map[string]any{
+		"error": map[string]any{
+			"message": "upstream stream ended unexpectedly",
+			"type":    "upstream_error",
+			"code":    http.StatusBadGateway,
+		},
+	})
+	return append(append([]byte("data: "), b...), '\n', '\n')
+}
+
+// guardStream installs a streamGuard on completion event streams.
+func guardStream(resp *http.Response) {
+	req := resp.Request
+	if req == nil || resp.StatusCode != http.StatusOK ||
+		!strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") ||
+		resp.Header.Get("Content-Encoding") != "" {
+		return
+	}
+	switch req.URL.Path {
+	case "/v1/chat/completions", "/v1/completions":
+	default:
+		return
+	}
+	resp.Body = &streamGuard{body: resp.Body}
+}
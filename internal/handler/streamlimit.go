@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamLimiter tracks open streaming requests per client.
+type streamLimiter struct {
+	max int
+
+	mu     sync.Mutex
+	active map[string]int
+}
+
+func newStreamLimiter(max int) *streamLimiter {
+	return &streamLimiter{max: max, active: make(map[string]int)}
+}
+
+func (l *streamLimiter) acquire(client string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[client] >= l.max {
+		streamsRejected.Inc()
+		return false
+	}
+	l.active[client]++
+	activeStreams.Inc()
+	return true
+}
+
+func (l *streamLimiter) release(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	activeStreams.Dec()
+	if l.active[client]--; l.active[client] <= 0 {
+		delete(l.active, client)
+	}
+}
+
+// stats reports the open stream count, for the debug endpoint.
+func (l *streamLimiter) stats() gin.H {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	total := 0
+	for _, n := range l.active {
+		total += n
+	}
+	return gin.H{"active": total, "clients": len(l.active), "max_per_client": l.max}
+}
+
+// clientID identifies the caller by a hash of the credential it reaches the
+// upstream with. Callers using the shared default key are told apart by IP,
+// which only comes from forwarding headers the router is configured to trust.
+func clientID(c *gin.Context) string {
+	cred := upstreamCredential(c.Request, proxyConf)
+	if cred == "" || cred == proxyConf.OpenAIKey {
+		return "ip:" + c.ClientIP()
+	}
+	sum := sha256.Sum256([]byte(cred))
+	return "token:" + hex.EncodeToString(sum[:8])
+}
+
+// limit holds a slot for the client for the lifetime of a streaming
+// request. Non-streaming requests are not counted.
+func (l *streamLimiter) limit(c *gin.Context) {
+	body, err := jsonBody(c)
+	if err != nil || !isStreaming(body) {
+		return
+	}
+	client := clientID(c)
+	if !l.acquire(client) {
+		writeError(c.Writer, http.StatusTooManyRequests, "rate_limit_error", "too many concurrent streams")
+		c.Abort()
+		return
+	}
+	defer l.release(client)
+	c.Next()
+}
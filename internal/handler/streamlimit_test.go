@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+func TestClientID(t *testing.T) {
+	defer func(conf *config.Config) { proxyConf = conf }(proxyConf)
+	gin.SetMode(gin.TestMode)
+
+	id := func(conf *config.Config, ip string, header http.Header) string {
+		proxyConf = conf
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header = header
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = req
+		return clientID(c)
+	}
+
+	bearer := &config.Config{OpenAIKey: "default-key"}
+	if a, b := id(bearer, "10.0.0.1", http.Header{"Authorization": {"Bearer null1"}}),
+		id(bearer, "10.0.0.1", http.Header{"Authorization": {"Bearer null2"}}); a != b {
+		t.Errorf("null tokens on the default key got different IDs %q and %q", a, b)
+	}
+	if a, b := id(bearer, "10.0.0.1", http.Header{"Authorization": {"Bearer sk-one"}}),
+		id(bearer, "10.0.0.1", http.Header{"Authorization": {"Bearer sk-two"}}); a == b {
+		t.Errorf("different keys share ID %q", a)
+	}
+
+	xAPIKey := &config.Config{AuthStyle: config.AuthStyleXAPIKey, OpenAIKey: "default-key"}
+	if a, b := id(xAPIKey, "10.0.0.1", http.Header{"X-Api-Key": {"key-one"}}),
+		id(xAPIKey, "10.0.0.1", http.Header{"X-Api-Key": {"key-two"}}); a == b {
+		t.Errorf("different x-api-key clients share ID %q", a)
+	}
+}
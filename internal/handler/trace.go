@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"time"
+)
+
+type timingsKey struct{}
+
+// requestTimings records the phases of a single upstream round trip.
+type requestTimings struct {
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+}
+
+// withTimings attaches an httptrace.ClientTrace to req that fills in a
+// requestTimings, which can later be read back with timingsFrom.
+func withTimings(req *http.Request) {
+	t := &requestTimings{start: time.Now()}
+	trace := &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { t.dnsStart = time.Now() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.dnsDone = time.Now() },
+		ConnectStart:         func(string, string) { t.connectStart = time.Now() },
+		ConnectDone:          func(string, string, error) { t.connectDone = time.Now() },
+		TLSHandshakeStart:    func() { t.tlsStart = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.tlsDone = time.Now() },
+		GotFirstResponseByte: func() { t.firstByte = time.Now() },
+	}
+	ctx := context.WithValue(req.Context(), timingsKey{}, t)
+	*req = *req.WithContext(httptrace.WithClientTrace(ctx, trace))
+}
+
+func timingsFrom(req *http.Request) *requestTimings {
+	if req == nil {
+		return nil
+	}
+	t, _ := req.Context().Value(timingsKey{}).(*requestTimings)
+	return t
+}
+
+func since(from, to time.Time) time.Duration {
+	if from.IsZero() || to.IsZero() {
+		return 0
+	}
+	return to.Sub(from)
+}
+
+func logTimings(req *http.Request, status int) {
+	t := timingsFrom(req)
+	if t == nil {
+		return
+	}
+	slog.Info("upstream timings",
+		"host", req.URL.Host,
+		"path", req.URL.Path,
+		"status", status,
+		"dns", since(t.dnsStart, t.dnsDone),
+		"connect", since(t.connectStart, t.connectDone),
+		"tls", since(t.tlsStart, t.tlsDone),
+		"first_byte", since(t.start, t.firstByte),
+		"total", time.Since(t.start))
+}
+
+type dispatchKey struct{}
+
+const upstreamLatencyHeader = "X-Upstream-Latency-Ms"
+
+// markDispatch records when req is handed to the transport.
+func markDispatch(req *http.Request) {
+	*req = *req.WithContext(context.WithValue(req.Context(), dispatchKey{}, time.Now()))
+}
+
+// setUpstreamLatency reports the time since markDispatch on resp.
+func setUpstreamLatency(resp *http.Response) {
+	if resp.Request == nil {
+		return
+	}
+	start, ok := resp.Request.Context().Value(dispatchKey{}).(time.Time)
+	if !ok {
+		return
+	}
+	resp.Header.Set(upstreamLatencyHeader, strconv.FormatInt(time.Since(start).Milliseconds(), 10))
+}
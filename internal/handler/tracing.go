@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	tracer    = otel.Tracer("github.com/orvice/openapi-proxy/internal/handler")
+	traceProp = propagation.TraceContext{}
+)
+
+// startSpan opens a span around a proxied request, continuing the caller's
+// trace when it sent a traceparent header.
+func startSpan(c *gin.Context) trace.Span {
+	ctx := c.Request.Context()
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		ctx = traceProp.Extract(ctx, propagation.HeaderCarrier(c.Request.Header))
+	}
+	ctx, span := tracer.Start(ctx, "proxy "+c.Request.URL.Path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.method", c.Request.Method),
+			attribute.String("http.target", c.Request.URL.Path),
+			attribute.String("upstream.endpoint", proxyConf.OpenAIEndpoint),
+		))
+	c.Request = c.Request.WithContext(ctx)
+	return span
+}
+
+// injectTraceContext passes the current span to the upstream.
+func injectTraceContext(req *http.Request) {
+	traceProp.Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+}
+
+// endSpanResponse records the upstream status on the request's span.
+func endSpanResponse(resp *http.Response) {
+	if resp.Request == nil {
+		return
+	}
+	span := trace.SpanFromContext(resp.Request.Context())
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	if model := resolvedModel(resp.Request); model != "" {
+		span.SetAttributes(attribute.String("model", model))
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+}
+
+// endSpanError records a failed upstream round trip on the request's span.
+func endSpanError(req *http.Request, status int, err error) {
+	span := trace.SpanFromContext(req.Context())
+	span.SetAttributes(attribute.Int("http.status_code", status))
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/orvice/openapi-proxy/internal/config"
+)
+
+// newTransport builds the transport used for upstream calls.
+func newTransport(conf *config.Config) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ResponseHeaderTimeout = conf.UpstreamTimeout
+	if conf.HTTPProxy != "" {
+		u, err := url.Parse(conf.HTTPProxy)
+		if err != nil {
+			return nil, err
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	if conf.TLSClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(conf.TLSClientCert, conf.TLSClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("load tls client certificate: %w", err)
+		}
+		t.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+	if conf.TLSInsecureSkipVerify {
+		slog.Warn("TLS certificate verification is DISABLED for the upstream, do not use in production",
+			"endpoint", conf.OpenAIEndpoint)
+		t.TLSClientConfig.InsecureSkipVerify = true
+	}
+	if conf.UnixSocket != "" {
+		var d net.Dialer
+		t.Proxy = nil
+		t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, "unix", conf.UnixSocket)
+		}
+	}
+	return t, nil
+}
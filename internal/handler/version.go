@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"runtime"
+
+	"github.com/gin-gonic/gin"
+	"github.com/orvice/openapi-proxy/internal/version"
+)
+
+// Version reports the running build and the upstream it proxies to.
+func Version(c *gin.Context) {
+	var upstream string
+	if u, err := url.Parse(proxyConf.OpenAIEndpoint); err == nil {
+		upstream = u.Host
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"version":    version.Version,
+		"commit":     version.Commit,
+		"build_time": version.BuildTime,
+		"go_version": runtime.Version(),
+		"upstream":   upstream,
+	})
+}
@@ -0,0 +1,39 @@
+package redact
+
+import (
+	"regexp"
+	"strings"
+)
+
+var keyPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`sk-[A-Za-z0-9_\-]{8,}`),
+	regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9_\-.=]{8,}`),
+}
+
+// Key masks a secret, keeping just enough to tell keys apart.
+func Key(key string) string {
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:3] + "..." + key[len(key)-4:]
+}
+
+// String masks anything in s that looks like an API key, along with any of
+// the given secrets.
+func String(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, Key(secret))
+		}
+	}
+	for _, p := range keyPatterns {
+		s = p.ReplaceAllStringFunc(s, func(m string) string {
+			sub := p.FindStringSubmatch(m)
+			if len(sub) > 1 {
+				return sub[1] + Key(m[len(sub[1]):])
+			}
+			return Key(m)
+		})
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+package redact
+
+import "testing"
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"", ""},
+		{"short", "*****"},
+		{"12345678", "********"},
+		{"sk-abcdefghijklmnop", "sk-...mnop"},
+	}
+	for _, tt := range tests {
+		if got := Key(tt.key); got != tt.want {
+			t.Errorf("Key(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		secrets []string
+		want    string
+	}{
+		{
+			name: "no keys",
+			s:    "upstream unavailable",
+			want: "upstream unavailable",
+		},
+		{
+			name: "openai key",
+			s:    "Incorrect API key provided: sk-proj-abcdefghijklmnop.",
+			want: "Incorrect API key provided: sk-...mnop.",
+		},
+		{
+			name: "short sk- prefix is left alone",
+			s:    "task-1234",
+			want: "task-1234",
+		},
+		{
+			name: "bearer token",
+			s:    "Authorization: Bearer abcdefghijklmnop",
+			want: "Authorization: Bearer abc...mnop",
+		},
+		{
+			name: "bearer is case-insensitive",
+			s:    "authorization: bearer abcdefghijklmnop",
+			want: "authorization: bearer abc...mnop",
+		},
+		{
+			name:    "configured secret",
+			s:       `{"error":"bad token AKIAEXAMPLESECRET"}`,
+			secrets: []string{"AKIAEXAMPLESECRET"},
+			want:    `{"error":"bad token AKI...CRET"}`,
+		},
+		{
+			name:    "empty secrets are ignored",
+			s:       "nothing to hide",
+			secrets: []string{"", ""},
+			want:    "nothing to hide",
+		},
+		{
+			name: "several keys",
+			s:    "sk-aaaaaaaaaaaa and sk-bbbbbbbbbbbb",
+			want: "sk-...aaaa and sk-...bbbb",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := String(tt.s, tt.secrets...); got != tt.want {
+				t.Errorf("String(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
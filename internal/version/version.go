@@ -0,0 +1,9 @@
+package version
+
+// Build information, set at build time via
+// -ldflags "-X github.com/orvice/openapi-proxy/internal/version.Version=...".
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)